@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"ppkgmgr/internal/data"
+	"ppkgmgr/internal/digest"
+	"ppkgmgr/internal/logger"
+	"ppkgmgr/pkg/req"
+)
+
+// downloader fetches every file installed by the command.
+var downloader req.Downloader = req.HTTPDownloader{}
+
+// target is a manifest file resolved to its download URL and output path.
+type target struct {
+	url  string
+	path string
+	// copies receive the file installed at path when out_dir lists
+	// several directories.
+	copies []string
+	file   data.File
+	sums   map[string]string
+	// writeDigest stores the digest of the installed file in a sidecar.
+	writeDigest bool
+}
+
+// install downloads t into a sibling ".part" file, applies the checks and
+// permissions there and only then renames it over the output path, so the
+// output never holds a partial or unverified file. The result is then copied
+// to every other destination the same way. It reports false when every
+// output already matched the expected digest and nothing was written.
+func install(t target) (bool, error) {
+	changed, err := installPath(t, t.path, func(part string) (int64, error) {
+		res, err := downloader.Fetch(context.Background(), req.DownloadRequest{URL: t.url, Path: part})
+		return res.Bytes, err
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, dst := range t.copies {
+		copied, err := installPath(t, dst, func(part string) (int64, error) {
+			return copyFile(t.path, part)
+		})
+		if err != nil {
+			return false, err
+		}
+		changed = changed || copied
+	}
+	return changed, nil
+}
+
+// installPath writes path through fetch, which fills the given part file.
+func installPath(t target, path string, fetch func(part string) (int64, error)) (bool, error) {
+	expected := expectedDigest(t.sums, t.file)
+	if t.sums != nil && "" == expected {
+		return false, fmt.Errorf("no checksum listed for %s", t.file.FileName)
+	}
+
+	if "" != expected {
+		if ok, actual, err := digest.Verify(path, expected); err == nil && ok {
+			logger.Std.Info(logger.Event{Event: "up_to_date", URL: t.url, Path: path, Digest: actual}, "up to date: %s\n", path)
+			return false, finish(t, path, actual)
+		}
+	}
+
+	perm, err := t.file.DirPerm()
+	if err != nil {
+		return false, err
+	}
+	if err := ensureOutDir(filepath.Dir(path), perm); err != nil {
+		return false, err
+	}
+
+	part := path + req.PartSuffix
+	dlsize, err := fetch(part)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(part)
+
+	if err := t.file.CheckSize(dlsize); err != nil {
+		return false, err
+	}
+
+	actual := ""
+	if "" != expected {
+		ok, sum, err := digest.Verify(part, expected)
+		if err == nil && !ok {
+			err = fmt.Errorf("digest mismatch: expected %s got %s", expected, sum)
+		}
+		if err != nil {
+			return false, err
+		}
+		actual = sum
+		logger.Std.Info(logger.Event{Event: "digest_verified", URL: t.url, Path: path, Digest: actual}, "")
+	} else if t.writeDigest {
+		if actual, err = digest.SumFile(part); err != nil {
+			return false, err
+		}
+	}
+
+	if err := keepMode(path, part); err != nil {
+		return false, err
+	}
+	if err := applyMode(t, part); err != nil {
+		return false, err
+	}
+
+	if err := os.Rename(part, path); err != nil {
+		return false, err
+	}
+
+	if t.writeDigest {
+		if err := digest.WriteSidecar(path, actual); err != nil {
+			return false, err
+		}
+	}
+
+	logger.Std.Info(logger.Event{Event: "download_finished", URL: t.url, Path: path, Bytes: dlsize, Digest: actual}, "downloaded: %s => %s\n", t.url, path)
+
+	return true, nil
+}
+
+// verifyPresent checks the outputs of t without downloading anything. Missing
+// outputs are reported and left alone; present ones are checked against the
+// expected digest when there is one.
+func verifyPresent(t target) error {
+	expected := expectedDigest(t.sums, t.file)
+	for _, path := range append([]string{t.path}, t.copies...) {
+		if _, err := os.Lstat(path); err != nil {
+			logger.Std.Info(logger.Event{Event: "skipped", URL: t.url, Path: path}, "skipped, offline: %s\n", path)
+			continue
+		}
+		if "" == expected {
+			continue
+		}
+		ok, actual, err := digest.Verify(path, expected)
+		if err == nil && !ok {
+			err = fmt.Errorf("digest mismatch: expected %s got %s", expected, actual)
+		}
+		if err != nil {
+			return err
+		}
+		logger.Std.Info(logger.Event{Event: "up_to_date", URL: t.url, Path: path, Digest: actual}, "up to date: %s\n", path)
+	}
+	return nil
+}
+
+// keepMode gives part the permissions of an existing output at path, so a
+// replaced file keeps the mode the user gave it.
+func keepMode(path string, part string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	return os.Chmod(part, fi.Mode().Perm())
+}
+
+// applyMode sets the permissions requested by the manifest on path.
+func applyMode(t target, path string) error {
+	if t.file.Executable {
+		return applyExecutable(path)
+	}
+	return nil
+}
+
+// finish applies the manifest's mode and sidecar to an output that was
+// already up to date.
+func finish(t target, path string, actual string) error {
+	if err := applyMode(t, path); err != nil {
+		return err
+	}
+	if t.writeDigest {
+		return digest.WriteSidecar(path, actual)
+	}
+	return nil
+}
+
+func loadChecksums(src string) (map[string]string, error) {
+	var raw []byte
+	var err error
+	if data.IsRemote(src) {
+		raw, err = req.Fetch(src)
+	} else {
+		raw, err = data.LoadRaw(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return digest.ParseSums(bytes.NewReader(raw))
+}
+
+func expectedDigest(sums map[string]string, fs data.File) string {
+	if sum, ok := sums[fs.FileName]; ok {
+		return sum
+	}
+	return sums[filepath.Base(fs.FileName)]
+}
+
+// copyFile copies src to dst and returns the number of bytes written.
+func copyFile(src string, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return 0, err
+	}
+	return n, nil
+}
+
+func ensureOutDir(dir string, perm os.FileMode) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	return os.MkdirAll(dir, perm)
+}
+
+func applyExecutable(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(path, fi.Mode()|0111)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"ppkgmgr/internal/data"
+	"ppkgmgr/internal/digest"
+	"ppkgmgr/internal/logger"
+	"ppkgmgr/pkg/req"
+	"strings"
+	"testing"
+)
+
+func TestInstall_RenameSubdir(t *testing.T) {
+
+	orgStdout := os.Stdout
+
+	defer func() {
+		os.Stdout = orgStdout
+	}()
+	os.Stdout = nil
+
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer tsrv.Close()
+
+	fs := data.File{FileName: "tool", Rename: "nested/dir/tool", OutDir: data.Dirs{t.TempDir()}}
+	path, err := fs.ResolvePath(data.ResolveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := install(target{url: tsrv.URL + "/tool", path: path, file: fs}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(fs.OutDir[0], "nested", "dir", "tool"))
+	if err != nil || string(got) != "hello" {
+		t.Errorf("exp is hello != %s (%v)", got, err)
+	}
+
+}
+
+func TestInstall_Copies(t *testing.T) {
+
+	orgStdout := os.Stdout
+
+	defer func() {
+		os.Stdout = orgStdout
+	}()
+	os.Stdout = nil
+
+	hits := 0
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte("hello"))
+	}))
+	defer tsrv.Close()
+
+	fs := data.File{FileName: "tool", OutDir: data.Dirs{t.TempDir(), t.TempDir()}, Executable: true}
+	paths, err := fs.ResolvePaths(data.ResolveOptions{})
+	if err != nil || len(paths) != 2 {
+		t.Fatalf("unexpected %v %v", paths, err)
+	}
+
+	if _, err := install(target{url: tsrv.URL + "/tool", path: paths[0], copies: paths[1:], file: fs}); err != nil {
+		t.Fatal(err)
+	}
+
+	if hits != 1 {
+		t.Errorf("exp is 1 request != %d", hits)
+	}
+	for _, p := range paths {
+		got, err := os.ReadFile(p)
+		if err != nil || string(got) != "hello" {
+			t.Errorf("%s: exp is hello != %s (%v)", p, got, err)
+		}
+		if fi, err := os.Stat(p); err != nil || fi.Mode()&0111 == 0 {
+			t.Errorf("%s: exp is executable", p)
+		}
+	}
+
+}
+
+func TestVerifyPresent(t *testing.T) {
+
+	orgStdout := os.Stdout
+
+	defer func() {
+		os.Stdout = orgStdout
+	}()
+	os.Stdout = nil
+
+	dir := t.TempDir()
+	present := filepath.Join(dir, "tool")
+	os.WriteFile(present, []byte("hello"), 0644)
+	missing := filepath.Join(dir, "missing")
+
+	fs := data.File{FileName: "tool"}
+	sum, _ := digest.SumFile(present)
+
+	if err := verifyPresent(target{path: present, copies: []string{missing}, file: fs, sums: map[string]string{"tool": sum}}); err != nil {
+		t.Errorf("exp is nil: %v", err)
+	}
+	if err := verifyPresent(target{path: present, file: fs, sums: map[string]string{"tool": strings.Repeat("0", 64)}}); err == nil {
+		t.Error("exp is digest mismatch")
+	}
+
+}
+
+func TestInstall_DigestMismatch(t *testing.T) {
+
+	orgStdout := os.Stdout
+	orgDownloader := downloader
+
+	defer func() {
+		os.Stdout = orgStdout
+		downloader = orgDownloader
+	}()
+	os.Stdout = nil
+
+	downloader = req.DownloaderFunc(func(url string, path string) (int64, error) {
+		return 3, os.WriteFile(path, []byte("bad"), 0644)
+	})
+
+	path := filepath.Join(t.TempDir(), "tool")
+	os.WriteFile(path, []byte("old"), 0644)
+	good, _ := digest.SumFile(writeTemp(t, "good"))
+
+	fs := data.File{FileName: "tool"}
+	_, err := install(target{url: "https://example.test/tool", path: path, file: fs, sums: map[string]string{"tool": good}})
+	if err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("exp is digest mismatch: %v", err)
+	}
+	if _, err := os.Stat(path + req.PartSuffix); !os.IsNotExist(err) {
+		t.Error("exp is part removed")
+	}
+	if got, _ := os.ReadFile(path); string(got) != "old" {
+		t.Errorf("exp is old output kept != %s", got)
+	}
+
+}
+
+func TestInstall_NotListed(t *testing.T) {
+
+	orgDownloader := downloader
+
+	defer func() {
+		downloader = orgDownloader
+	}()
+
+	downloader = req.DownloaderFunc(func(url string, path string) (int64, error) {
+		t.Error("exp is no fetch")
+		return 0, nil
+	})
+
+	path := filepath.Join(t.TempDir(), "tool")
+	fs := data.File{FileName: "tool"}
+	_, err := install(target{url: "https://example.test/tool", path: path, file: fs, sums: map[string]string{"other": strings.Repeat("0", 64)}})
+	if err == nil || !strings.Contains(err.Error(), "no checksum listed for tool") {
+		t.Errorf("exp is not listed err: %v", err)
+	}
+
+}
+
+func writeTemp(t *testing.T, body string) string {
+	path := filepath.Join(t.TempDir(), "tmp")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInstall_UpToDate(t *testing.T) {
+
+	orgStdout := os.Stdout
+	orgDownloader := downloader
+
+	defer func() {
+		os.Stdout = orgStdout
+		downloader = orgDownloader
+	}()
+	os.Stdout = nil
+
+	downloader = req.DownloaderFunc(func(url string, path string) (int64, error) {
+		t.Error("exp is no fetch")
+		return 0, nil
+	})
+
+	path := writeTemp(t, "hello")
+	sum, _ := digest.SumFile(path)
+
+	changed, err := install(target{url: "https://example.test/tmp", path: path, file: data.File{FileName: "tmp"}, sums: map[string]string{"tmp": sum}})
+	if err != nil || changed {
+		t.Errorf("exp is unchanged: %v %v", changed, err)
+	}
+
+}
+
+func TestInstall_DigestEvents(t *testing.T) {
+
+	log, restore := captureLog()
+	defer restore()
+
+	var urls []string
+	defer stubDownloader(t, &urls)()
+
+	defer func() {
+		logger.Std.Format = logger.FormatText
+	}()
+	logger.Std.Format = logger.FormatJSON
+
+	sum, _ := digest.SumFile(writeTemp(t, "hello"))
+	path := filepath.Join(t.TempDir(), "tool")
+	tgt := target{url: "https://example.test/tool", path: path, file: data.File{FileName: "tool"}, sums: map[string]string{"tool": sum}}
+
+	if _, err := install(tgt); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyPresent(tgt); err != nil {
+		t.Fatal(err)
+	}
+
+	out := log.String()
+	for _, ev := range []string{"digest_verified", "download_finished", "up_to_date"} {
+		if !strings.Contains(out, `"event":"`+ev+`"`) {
+			t.Errorf("exp is %s event in %s", ev, out)
+		}
+	}
+	if strings.Count(out, `"digest":"`+sum+`"`) != 3 {
+		t.Errorf("exp is digest on every event in %s", out)
+	}
+
+}
+
+func TestInstall_KeepMode(t *testing.T) {
+
+	orgStdout := os.Stdout
+
+	defer func() {
+		os.Stdout = orgStdout
+	}()
+	os.Stdout = nil
+
+	var urls []string
+	defer stubDownloader(t, &urls)()
+
+	path := writeTemp(t, "old")
+	os.Chmod(path, 0750)
+
+	if _, err := install(target{url: "https://example.test/tmp", path: path, file: data.File{FileName: "tmp"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode().Perm() != 0750 {
+		t.Errorf("exp is mode kept: %v %v", fi.Mode(), err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "hello" {
+		t.Errorf("exp is replaced != %s", got)
+	}
+
+}
@@ -1,17 +1,44 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"ppkgmgr/internal/data"
+	"ppkgmgr/internal/digest"
+	"ppkgmgr/internal/logger"
 	"ppkgmgr/pkg/req"
+	"runtime"
+	"strconv"
+	"strings"
 )
 
 var (
 	Version = "0.0.0"
 )
 
+// Exit codes returned by the command.
+const (
+	ExitOK       = 0
+	ExitUsage    = 1
+	ExitNotFound = 2
+	ExitParse    = 3
+	ExitDownload = 4
+)
+
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
 func defaultData(val string, def string) string {
 	if "" == val {
 		return def
@@ -19,46 +46,391 @@ func defaultData(val string, def string) string {
 	return val
 }
 
+func envBool(name string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(name))
+	return v
+}
+
+func reportError(url string, path string, err error) {
+	logger.Std.Error(logger.Event{URL: url, Path: path, Error: err.Error()}, "Err: %s\n", err.Error())
+}
+
+// exitUsage reports a command line error through the logger and exits with
+// ExitUsage.
+func exitUsage(format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	logger.Std.Error(logger.Event{Error: strings.TrimSpace(msg)}, "%s", msg)
+	os.Exit(ExitUsage)
+}
+
 func main() {
 
 	var spider bool
 	var ver bool
+	var quiet bool
+	var logFormat string
+	var manifestDigest string
+	var userAgent string
+	var outputRoot string
+	var only stringList
+	var skip stringList
+	var checksumFile string
+	var failFast bool
+	var insecure bool
+	var caCert string
+	var baseDir string
+	var writeDigest bool
+	var rateLimit string
+	var maxSize string
+	var skipExisting bool
+	var checkVersion bool
+	var releaseURL string
+	var trace bool
+	var maxRedirects int
+	var noRedirect bool
+	var outputTemplate string
+	var netrc bool
+	var dest string
+	var strictSize bool
+	var offline bool
 
 	flag.BoolVar(&spider, "spider", false, "no act")
 	flag.BoolVar(&ver, "v", false, "print version")
+	flag.BoolVar(&quiet, "quiet", false, "suppress informational output")
+	flag.StringVar(&logFormat, "log-format", logger.FormatText, "log format (text|json)")
+	flag.StringVar(&manifestDigest, "manifest-digest", "", "expected sha256 digest of the manifest")
+	flag.StringVar(&userAgent, "user-agent", defaultData(os.Getenv("PPKGMGR_USER_AGENT"), "ppkgmgr/"+Version), "User-Agent header sent with downloads")
+	flag.StringVar(&outputRoot, "output-root", "", "write every file under this directory")
+	flag.Var(&only, "only", "download only files matching these globs (repeatable, comma-separated)")
+	flag.Var(&skip, "skip", "skip files matching these globs (repeatable, comma-separated)")
+	flag.StringVar(&checksumFile, "checksum-file", "", "SHA256SUMS file or URL used for repositories without checksums_url; files it does not list fail")
+	flag.BoolVar(&failFast, "fail-fast", false, "stop at the first download error")
+	flag.BoolVar(&insecure, "insecure", envBool("PPKGMGR_INSECURE"), "skip TLS certificate verification")
+	flag.StringVar(&caCert, "cacert", "", "PEM bundle of CA certificates trusted for downloads")
+	flag.StringVar(&baseDir, "base-dir", "", "resolve relative out_dir against this directory, or \"manifest\" for the manifest's own directory")
+	flag.BoolVar(&writeDigest, "write-digest", false, "write a .sha256 sidecar next to each installed file")
+	flag.StringVar(&rateLimit, "rate-limit", "", "limit requests per host, e.g. 5/s (default unlimited)")
+	flag.StringVar(&maxSize, "max-size", "", "reject downloads larger than this, e.g. 100MB (default unlimited)")
+	flag.BoolVar(&skipExisting, "skip-existing", false, "leave files that already exist untouched instead of replacing them")
+	flag.BoolVar(&checkVersion, "check-version", false, "check whether a newer release is available")
+	flag.StringVar(&releaseURL, "release-url", defaultReleaseURL, "release API queried by -check-version")
+	flag.BoolVar(&trace, "trace", false, "log HTTP requests and responses to stderr")
+	flag.IntVar(&maxRedirects, "max-redirects", req.MaxRedirects, "maximum redirects followed per download")
+	flag.BoolVar(&noRedirect, "no-redirect", false, "fail instead of following redirects")
+	flag.StringVar(&outputTemplate, "output-template", "", "rewrite output file names using {name}, {base} and {ext}")
+	flag.BoolVar(&netrc, "netrc", false, "use credentials from $NETRC or ~/.netrc for download hosts")
+	flag.StringVar(&dest, "dest", "", "write every file into this directory instead of its out_dir")
+	flag.BoolVar(&strictSize, "strict-size", false, "fail downloads shorter or longer than their Content-Length instead of warning")
+	flag.BoolVar(&offline, "offline", false, "never access the network; only verify files already present")
 	flag.Parse()
 
-	if ver {
+	if !logger.ValidFormat(logFormat) {
+		fmt.Printf("unsupported log format: %s\n", logFormat)
+		os.Exit(ExitUsage)
+	}
+
+	logger.Std.Quiet = quiet
+	logger.Std.Format = logFormat
+	req.UserAgent = userAgent
+	req.Insecure = insecure
+	req.StrictSize = strictSize
+	req.Offline = offline
+	req.MaxRedirects = maxRedirects
+	if noRedirect || maxRedirects < 0 {
+		req.MaxRedirects = 0
+	}
+	if trace {
+		req.Trace = os.Stderr
+	}
+	if insecure {
+		fmt.Fprintln(os.Stderr, "Warning: TLS certificate verification is disabled")
+	}
+	if "" != rateLimit {
+		rate, err := req.ParseRate(rateLimit)
+		if err != nil {
+			exitUsage("%s\n", err.Error())
+		}
+		req.Limiter = req.NewHostLimiter(rate)
+	}
+	if "" != maxSize {
+		size, err := req.ParseSize(maxSize)
+		if err != nil {
+			exitUsage("%s\n", err.Error())
+		}
+		req.MaxSize = size
+	}
+	if "" != caCert {
+		pool, err := req.LoadCACert(caCert)
+		if err != nil {
+			exitUsage("invalid cacert: %s\n", err.Error())
+		}
+		req.RootCAs = pool
+	}
+
+	if netrc {
+		n, err := req.LoadNetrc(req.DefaultNetrcPath())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: netrc not used: %s\n", err.Error())
+		}
+		req.Netrc = n
+	}
+
+	if ver || checkVersion {
 		fmt.Printf("Version : %s\n", Version)
-		os.Exit(0)
+		if checkVersion {
+			checkLatest(releaseURL)
+		}
+		os.Exit(ExitOK)
 	}
 
 	if len(flag.Args()) < 1 {
-		fmt.Println("require args")
-		os.Exit(1)
+		exitUsage("require args\n")
+	}
+
+	filter, err := data.NewFilter(only, skip)
+	if err != nil {
+		exitUsage("invalid pattern: %s\n", err.Error())
+	}
+
+	tmpl, err := data.ParseTemplate(outputTemplate)
+	if err != nil {
+		exitUsage("%s\n", err.Error())
+	}
+
+	if "" != manifestDigest && flag.NArg() > 1 {
+		exitUsage("-manifest-digest requires a single manifest\n")
+	}
+
+	o := options{
+		spider:         spider,
+		manifestDigest: manifestDigest,
+		outputRoot:     outputRoot,
+		filter:         filter,
+		template:       tmpl,
+		checksumFile:   checksumFile,
+		failFast:       failFast,
+		baseDir:        baseDir,
+		writeDigest:    writeDigest,
+		skipExisting:   skipExisting,
+		dest:           dest,
+		offline:        offline,
+	}
+
+	code := ExitOK
+	for _, arg := range flag.Args() {
+		if flag.NArg() > 1 {
+			logger.Std.Info(logger.Event{Event: "manifest", Path: arg}, "==> %s\n", arg)
+		}
+		c := run(arg, o)
+		if c > code {
+			code = c
+		}
+		if failFast && c == ExitDownload {
+			break
+		}
+	}
+	os.Exit(code)
+
+}
+
+// options carries the command line settings shared by every manifest.
+type options struct {
+	spider         bool
+	manifestDigest string
+	outputRoot     string
+	filter         data.Filter
+	template       data.Template
+	checksumFile   string
+	failFast       bool
+	baseDir        string
+	writeDigest    bool
+	skipExisting   bool
+	dest           string
+	offline        bool
+}
+
+// run installs the files of the manifest at arg and returns its exit code.
+func run(arg string, o options) int {
+
+	path := data.LocalPath(arg)
+
+	if "-" != path {
+		if _, err := os.Stat(path); err != nil {
+			logger.Std.Error(logger.Event{Path: arg, Error: "not found path"}, "not found path\n")
+			return ExitNotFound
+		}
+	}
+
+	raw, err := data.LoadRaw(path)
+	if err != nil {
+		logger.Std.Errorf("Err: %s\n", err.Error())
+		return ExitNotFound
+	}
+
+	if "" != o.manifestDigest {
+		ok, actual, err := digest.VerifyBytes(raw, o.manifestDigest)
+		if err != nil {
+			logger.Std.Errorf("Err: %s\n", err.Error())
+			return ExitParse
+		}
+		if !ok {
+			logger.Std.Errorf("Err: manifest digest mismatch: expected %s got %s\n", o.manifestDigest, actual)
+			return ExitParse
+		}
+	}
+
+	fd, err := data.ParseFormat(bytes.NewReader(raw), data.FormatOf(path))
+	if err != nil {
+		logger.Std.Errorf("Err: %s\n", err.Error())
+		return ExitParse
+	}
+
+	fd, err = data.ResolveIncludes(fd, path)
+	if err != nil {
+		logger.Std.Errorf("Err: %s\n", err.Error())
+		return ExitParse
 	}
 
-	path := flag.Arg(0)
+	if err := fd.RequireVersion(Version); err != nil {
+		logger.Std.Errorf("Err: %s\n", err.Error())
+		return ExitParse
+	}
 
-	if _, err := os.Stat(path); err != nil {
-		fmt.Println("not found path")
-		os.Exit(2)
+	if err := fd.Validate(); err != nil {
+		logger.Std.Errorf("Err: %s\n", err.Error())
+		return ExitParse
+	}
+
+	base := o.baseDir
+	if "manifest" == base {
+		base = ""
+		if "-" != path {
+			base = filepath.Dir(path)
+		}
 	}
 
-	fd := data.Parse(path)
+	resolve := data.ResolveOptions{Base: base, Template: o.template, Dest: o.dest}
+	checksums := map[string]map[string]string{}
+	hadFailure := false
+	downloaded, skipped, failed := 0, 0, 0
+	// fail reports err and tells the caller to stop when -fail-fast is set.
+	fail := func(url string, path string, err error) bool {
+		reportError(url, path, err)
+		failed++
+		hadFailure = true
+		return o.failFast
+	}
 
+repos:
 	for _, repo := range fd.Repo {
+		var sums map[string]string
+		src := defaultData(repo.ChecksumsURL, o.checksumFile)
+		if o.offline && data.IsRemote(src) {
+			logger.Std.Info(logger.Event{Event: "skipped", URL: src}, "skipped, offline: %s\n", src)
+			src = ""
+		}
+		if "" != src && !o.spider {
+			if _, ok := checksums[src]; !ok {
+				loaded, err := loadChecksums(src)
+				if err != nil {
+					if fail(src, "", err) {
+						break repos
+					}
+					continue
+				}
+				checksums[src] = loaded
+			}
+			sums = checksums[src]
+		}
 		for _, fs := range repo.Files {
-			dlurl := fmt.Sprintf("%s/%s", repo.Url, fs.FileName)
-			outdir := defaultData(fs.OutDir, ".")
-			outname := defaultData(fs.Rename, fs.FileName)
-			dlpath := fmt.Sprintf("%s/%s", outdir, outname)
-			if spider == true {
-				fmt.Printf("%s   %s\n", dlurl, dlpath)
+			if !fs.IsEnabled() || !fs.MatchesPlatform(runtime.GOOS, runtime.GOARCH) {
+				skipped++
+				continue
+			}
+			dlurl, fs, err := repo.Locate(fs)
+			if err != nil {
+				if fail(dlurl, "", err) {
+					break repos
+				}
+				continue
+			}
+			resolved, err := fs.ResolvePaths(resolve)
+			if err != nil {
+				if fail(dlurl, "", err) {
+					break repos
+				}
+				continue
+			}
+			var dlpaths []string
+			rejected := false
+			for _, dlpath := range resolved {
+				if !o.filter.Allow(fs, dlpath) {
+					continue
+				}
+				if "" != o.outputRoot {
+					rebased, err := data.Rebase(o.outputRoot, dlpath)
+					if err != nil {
+						if fail(dlurl, dlpath, err) {
+							break repos
+						}
+						rejected = true
+						continue
+					}
+					dlpath = rebased
+				}
+				if o.spider == true {
+					fmt.Printf("%s   %s\n", dlurl, dlpath)
+					continue
+				}
+				if o.skipExisting {
+					if _, err := os.Lstat(dlpath); err == nil {
+						logger.Std.Info(logger.Event{Event: "skipped", URL: dlurl, Path: dlpath}, "skipped, exists: %s\n", dlpath)
+						continue
+					}
+				}
+				dlpaths = append(dlpaths, dlpath)
+			}
+			// a file counts as skipped only when none of its destinations is left
+			if len(dlpaths) == 0 {
+				if !rejected {
+					skipped++
+				}
+				continue
+			}
+			dlpath := dlpaths[0]
+			t := target{url: dlurl, path: dlpath, copies: dlpaths[1:], file: fs, sums: sums, writeDigest: o.writeDigest}
+			if o.offline {
+				if err := verifyPresent(t); err != nil {
+					if fail(dlurl, dlpath, err) {
+						break repos
+					}
+					continue
+				}
+				skipped++
+				continue
+			}
+			changed, err := install(t)
+			if err != nil {
+				if fail(dlurl, dlpath, err) {
+					break repos
+				}
+				continue
+			}
+			if changed {
+				downloaded++
 			} else {
-				req.Download(dlurl, dlpath)
+				skipped++
 			}
 		}
 	}
 
+	if !o.spider {
+		logger.Std.Info(logger.Event{Event: "summary"}, "downloaded %d, skipped %d, failed %d\n", downloaded, skipped, failed)
+	}
+
+	if hadFailure {
+		return ExitDownload
+	}
+
+	return ExitOK
 }
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"ppkgmgr/internal/data"
+	"ppkgmgr/internal/digest"
+	"ppkgmgr/internal/logger"
+	"ppkgmgr/pkg/req"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// stubDownloader writes "hello" to every requested path and records the
+// URLs it was asked for.
+func stubDownloader(t *testing.T, urls *[]string) func() {
+	org := downloader
+	downloader = req.DownloaderFunc(func(url string, path string) (int64, error) {
+		*urls = append(*urls, url)
+		return 5, os.WriteFile(path, []byte("hello"), 0644)
+	})
+	return func() {
+		downloader = org
+	}
+}
+
+// captureLog sends logger output to the returned buffer.
+func captureLog() (*bytes.Buffer, func()) {
+	var buf bytes.Buffer
+	org := logger.Std.Out
+	logger.Std.Out = &buf
+	return &buf, func() {
+		logger.Std.Out = org
+	}
+}
+
+func writeManifest(t *testing.T, body string) string {
+	path := filepath.Join(t.TempDir(), "manifest.yml")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun_OutputRootFilter(t *testing.T) {
+
+	orgStdout := os.Stdout
+
+	defer func() {
+		os.Stdout = orgStdout
+	}()
+	os.Stdout = nil
+
+	var urls []string
+	defer stubDownloader(t, &urls)()
+
+	manifest := writeManifest(t, `
+repositories:
+  - url: https://example.test
+    files:
+      - file_name: a
+        out_dir: ./bin
+      - file_name: b
+        out_dir: ./bin
+      - file_name: c
+        out_dir: ../../escape
+`)
+	filter, err := data.NewFilter([]string{"bin/a"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+
+	if code := run(manifest, options{outputRoot: root, filter: filter}); code != ExitOK {
+		t.Errorf("exp is %d != %d", ExitOK, code)
+	}
+
+	sort.Strings(urls)
+	if len(urls) != 1 || urls[0] != "https://example.test/a" {
+		t.Errorf("exp is only a fetched != %v", urls)
+	}
+	if _, err := os.Stat(filepath.Join(root, "bin", "a")); err != nil {
+		t.Errorf("exp is a under output root: %v", err)
+	}
+
+}
+
+func TestRun_UpToDate(t *testing.T) {
+
+	log, restore := captureLog()
+	defer restore()
+
+	var urls []string
+	defer stubDownloader(t, &urls)()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "tool"), []byte("hello"), 0644)
+	sum, _ := digest.SumFile(filepath.Join(dir, "tool"))
+	sums := filepath.Join(dir, "SHA256SUMS")
+	os.WriteFile(sums, []byte(sum+"  tool\n"), 0644)
+
+	manifest := writeManifest(t, `
+repositories:
+  - url: https://example.test
+    files:
+      - file_name: tool
+        out_dir: `+dir+`
+`)
+
+	if code := run(manifest, options{checksumFile: sums}); code != ExitOK {
+		t.Errorf("exp is %d != %d", ExitOK, code)
+	}
+	if len(urls) != 0 {
+		t.Errorf("exp is no fetch != %v", urls)
+	}
+	if !strings.Contains(log.String(), "downloaded 0, skipped 1, failed 0") {
+		t.Errorf("exp is counted as skipped in %q", log.String())
+	}
+
+}
+
+func TestRun_SkipExisting(t *testing.T) {
+
+	log, restore := captureLog()
+	defer restore()
+
+	var urls []string
+	defer stubDownloader(t, &urls)()
+
+	kept, fresh := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(kept, "tool"), []byte("mine"), 0644)
+
+	manifest := writeManifest(t, `
+repositories:
+  - url: https://example.test
+    files:
+      - file_name: tool
+        out_dir: `+kept+`
+      - file_name: tool
+        out_dir: [`+kept+`, `+fresh+`]
+`)
+
+	if code := run(manifest, options{skipExisting: true}); code != ExitOK {
+		t.Errorf("exp is %d != %d", ExitOK, code)
+	}
+
+	if got, _ := os.ReadFile(filepath.Join(kept, "tool")); string(got) != "mine" {
+		t.Errorf("exp is existing output untouched != %s", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(fresh, "tool")); string(got) != "hello" {
+		t.Errorf("exp is missing destination installed != %s", got)
+	}
+	if len(urls) != 1 {
+		t.Errorf("exp is one fetch for the missing destination != %v", urls)
+	}
+	if !strings.Contains(log.String(), "downloaded 1, skipped 1, failed 0") {
+		t.Errorf("unexpected summary in %q", log.String())
+	}
+
+}
+
+func TestRun_OfflineRemoteChecksums(t *testing.T) {
+
+	log, restore := captureLog()
+	defer restore()
+
+	var urls []string
+	defer stubDownloader(t, &urls)()
+
+	defer func() {
+		req.Offline = false
+	}()
+	req.Offline = true
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "tool"), []byte("hello"), 0644)
+
+	manifest := writeManifest(t, `
+repositories:
+  - url: https://example.test
+    checksums_url: https://example.test/SHA256SUMS
+    files:
+      - file_name: tool
+        out_dir: `+dir+`
+      - file_name: missing
+        out_dir: `+dir+`
+`)
+
+	if code := run(manifest, options{offline: true}); code != ExitOK {
+		t.Errorf("exp is %d != %d", ExitOK, code)
+	}
+	if len(urls) != 0 {
+		t.Errorf("exp is no fetch != %v", urls)
+	}
+	out := log.String()
+	for _, exp := range []string{"skipped, offline: https://example.test/SHA256SUMS", "skipped, offline: " + dir + "/missing", "downloaded 0, skipped 2, failed 0"} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("exp is %q in %q", exp, out)
+		}
+	}
+
+}
+
+func TestRun_NotFoundJSON(t *testing.T) {
+
+	log, restore := captureLog()
+	defer restore()
+
+	defer func() {
+		logger.Std.Format = logger.FormatText
+	}()
+	logger.Std.Format = logger.FormatJSON
+
+	missing := filepath.Join(t.TempDir(), "missing.yml")
+	if code := run(missing, options{}); code != ExitNotFound {
+		t.Errorf("exp is %d != %d", ExitNotFound, code)
+	}
+
+	exp := `{"event":"error","path":"` + missing + `","error":"not found path","message":"not found path"}` + "\n"
+	if log.String() != exp {
+		t.Errorf("exp is %s != %s", exp, log.String())
+	}
+
+}
+
+func TestRun_FailFast(t *testing.T) {
+
+	log, restore := captureLog()
+	defer restore()
+
+	var urls []string
+	org := downloader
+	defer func() {
+		downloader = org
+	}()
+	downloader = req.DownloaderFunc(func(url string, path string) (int64, error) {
+		urls = append(urls, url)
+		return 0, os.ErrPermission
+	})
+
+	dir := t.TempDir()
+	manifest := writeManifest(t, `
+repositories:
+  - url: https://example.test
+    files:
+      - file_name: a
+        out_dir: `+dir+`
+      - file_name: b
+        out_dir: `+dir+`
+`)
+
+	if code := run(manifest, options{failFast: true}); code != ExitDownload {
+		t.Errorf("exp is %d != %d", ExitDownload, code)
+	}
+	if len(urls) != 1 {
+		t.Errorf("exp is stop after first failure != %v", urls)
+	}
+	if !strings.Contains(log.String(), "downloaded 0, skipped 0, failed 1") {
+		t.Errorf("exp is summary in %q", log.String())
+	}
+
+}
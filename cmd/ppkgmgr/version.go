@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"ppkgmgr/internal/version"
+	"ppkgmgr/pkg/req"
+)
+
+const defaultReleaseURL = "https://api.github.com/repos/pirakansa/ppkgmgr/releases/latest"
+
+// checkLatest reports whether releaseURL announces a release newer than the
+// running Version. Failures are only warnings.
+func checkLatest(releaseURL string) {
+	msg, err := latestStatus(releaseURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot check latest version: %s\n", err.Error())
+		return
+	}
+	fmt.Println(msg)
+}
+
+// latestStatus compares the release announced by releaseURL with Version.
+func latestStatus(releaseURL string) (string, error) {
+	raw, err := req.Fetch(releaseURL)
+	if err != nil {
+		return "", err
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.Unmarshal(raw, &release); err != nil {
+		return "", err
+	}
+
+	latest, err := version.Parse(release.TagName)
+	if err != nil {
+		return "", err
+	}
+	current, err := version.Parse(Version)
+	if err != nil {
+		return "", err
+	}
+
+	if version.Compare(latest, current) > 0 {
+		return fmt.Sprintf("newer version v%s available", latest), nil
+	}
+	return "up to date", nil
+}
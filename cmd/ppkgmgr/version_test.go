@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLatestStatus(t *testing.T) {
+
+	orgVersion := Version
+
+	defer func() {
+		Version = orgVersion
+	}()
+	Version = "1.0.0"
+
+	bodies := map[string]string{
+		"/newer":     `{"tag_name": "v1.2.0"}`,
+		"/same":      `{"tag_name": "v1.0.0"}`,
+		"/malformed": `{"tag_name": `,
+		"/badtag":    `{"tag_name": "latest"}`,
+	}
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(bodies[r.URL.Path]))
+	}))
+	defer tsrv.Close()
+
+	if msg, err := latestStatus(tsrv.URL + "/newer"); err != nil || msg != "newer version v1.2.0 available" {
+		t.Errorf("unexpected %q %v", msg, err)
+	}
+	if msg, err := latestStatus(tsrv.URL + "/same"); err != nil || msg != "up to date" {
+		t.Errorf("unexpected %q %v", msg, err)
+	}
+	for _, p := range []string{"/malformed", "/badtag"} {
+		if _, err := latestStatus(tsrv.URL + p); err == nil {
+			t.Errorf("%s: exp is err", p)
+		}
+	}
+
+}
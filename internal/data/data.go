@@ -1,36 +1,410 @@
 package data
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"ppkgmgr/internal/version"
+	"strconv"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	yaml "gopkg.in/yaml.v3"
 )
 
 type FileData struct {
-	Repo []Repositories `yaml:"repositories"`
+	MinVersion string            `yaml:"min_version,omitempty" json:"min_version,omitempty" toml:"min_version,omitempty"`
+	Include    []string          `yaml:"include,omitempty" json:"include,omitempty" toml:"include,omitempty"`
+	Vars       map[string]string `yaml:"vars,omitempty" json:"vars,omitempty" toml:"vars,omitempty"`
+	Repo       []Repositories    `yaml:"repositories" json:"repositories" toml:"repositories"`
 }
 
 type Repositories struct {
-	Comment string `yaml:"_comment"`
-	Url     string `yaml:"url"`
-	Files   []File `yaml:"files"`
+	Comment      string `yaml:"_comment" json:"_comment,omitempty" toml:"_comment,omitempty"`
+	Url          string `yaml:"url" json:"url" toml:"url"`
+	ChecksumsURL string `yaml:"checksums_url,omitempty" json:"checksums_url,omitempty" toml:"checksums_url,omitempty"`
+	Files        []File `yaml:"files" json:"files" toml:"files"`
 }
 
 type File struct {
-	FileName string `yaml:"file_name"`
-	Rename   string `yaml:"rename,omitempty"`
-	OutDir   string `yaml:"out_dir"`
+	FileName   string `yaml:"file_name" json:"file_name" toml:"file_name"`
+	Rename     string `yaml:"rename,omitempty" json:"rename,omitempty" toml:"rename,omitempty"`
+	OutDir     Dirs   `yaml:"out_dir" json:"out_dir" toml:"out_dir"`
+	Executable bool   `yaml:"executable,omitempty" json:"executable,omitempty" toml:"executable,omitempty"`
+	DirMode    string `yaml:"dir_mode,omitempty" json:"dir_mode,omitempty" toml:"dir_mode,omitempty"`
+	Size       int64  `yaml:"size,omitempty" json:"size,omitempty" toml:"size,omitempty"`
+	OS         string `yaml:"os,omitempty" json:"os,omitempty" toml:"os,omitempty"`
+	Arch       string `yaml:"arch,omitempty" json:"arch,omitempty" toml:"arch,omitempty"`
+	Enabled    *bool  `yaml:"enabled,omitempty" json:"enabled,omitempty" toml:"enabled,omitempty"`
+}
+
+// Dirs holds one or more output directories. Manifests may write it as a
+// single string or as a list.
+type Dirs []string
+
+func (d *Dirs) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var s string
+		if err := value.Decode(&s); err != nil {
+			return err
+		}
+		*d = Dirs{s}
+		return nil
+	}
+	var l []string
+	if err := value.Decode(&l); err != nil {
+		return err
+	}
+	*d = l
+	return nil
+}
+
+func (d *Dirs) UnmarshalJSON(raw []byte) error {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		*d = Dirs{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(raw, &l); err != nil {
+		return err
+	}
+	*d = l
+	return nil
+}
+
+func (d *Dirs) UnmarshalTOML(v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		*d = Dirs{v}
+		return nil
+	case []interface{}:
+		l := make(Dirs, 0, len(v))
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return fmt.Errorf("out_dir: expected a string, got %T", e)
+			}
+			l = append(l, s)
+		}
+		*d = l
+		return nil
+	}
+	return fmt.Errorf("out_dir: expected a string or a list of strings, got %T", v)
+}
+
+// Manifest formats understood by the parser.
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+const DefaultDirMode os.FileMode = 0755
+
+// DirPerm returns the permissions used when creating the output directory.
+func (f File) DirPerm() (os.FileMode, error) {
+	if "" == f.DirMode {
+		return DefaultDirMode, nil
+	}
+	mode, err := strconv.ParseUint(f.DirMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid dir_mode %q: expected octal permissions like \"0700\"", f.DirMode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// CheckSize reports an error when a size is declared and n does not match it.
+func (f File) CheckSize(n int64) error {
+	if f.Size > 0 && n != f.Size {
+		return fmt.Errorf("size mismatch: expected %d got %d", f.Size, n)
+	}
+	return nil
+}
+
+// IsEnabled reports whether the file should be processed. Files are enabled
+// unless the manifest sets enabled to false.
+func (f File) IsEnabled() bool {
+	return f.Enabled == nil || *f.Enabled
+}
+
+// MatchesPlatform reports whether the file applies to goos and goarch. An
+// empty os or arch matches any platform.
+func (f File) MatchesPlatform(goos string, goarch string) bool {
+	return ("" == f.OS || f.OS == goos) && ("" == f.Arch || f.Arch == goarch)
+}
+
+// OutputDirs returns every out_dir, resolving relative values against base
+// when base is not empty. A file without out_dir goes to ".".
+func (f File) OutputDirs(base string) []string {
+	dirs := []string(f.OutDir)
+	if len(dirs) == 0 {
+		dirs = []string{""}
+	}
+	out := make([]string, 0, len(dirs))
+	for _, outdir := range dirs {
+		if "" == outdir {
+			outdir = "."
+		}
+		if "" != base && !filepath.IsAbs(outdir) {
+			outdir = filepath.Join(base, outdir)
+		}
+		out = append(out, outdir)
+	}
+	return out
+}
+
+// ResolveOptions adjusts how ResolvePath builds output paths.
+type ResolveOptions struct {
+	// Base resolves a relative out_dir when not empty.
+	Base string
+	// Template rewrites the output file name.
+	Template Template
+	// Dest replaces every out_dir when not empty.
+	Dest string
+}
+
+// ResolvePath returns the output path of the file under its first out_dir.
+func (f File) ResolvePath(opts ResolveOptions) (string, error) {
+	paths, err := f.ResolvePaths(opts)
+	if err != nil {
+		return "", err
+	}
+	return paths[0], nil
+}
+
+// ResolvePaths returns the output path of the file under each out_dir,
+// rejecting a rename or file_name that would escape out_dir.
+func (f File) ResolvePaths(opts ResolveOptions) ([]string, error) {
+	name := f.Rename
+	if "" == name {
+		name = f.FileName
+	}
+	if applied := opts.Template.Apply(name); applied != name {
+		if _, last := path.Split(applied); "" == last {
+			return nil, fmt.Errorf("output name is empty after applying the output template to %q", name)
+		}
+		name = applied
+	}
+
+	outdirs := f.OutputDirs(opts.Base)
+	if "" != opts.Dest {
+		outdirs = []string{opts.Dest}
+	}
+
+	if strings.HasSuffix(name, "/") || strings.HasSuffix(name, string(filepath.Separator)) {
+		return nil, fmt.Errorf("output name %q is a directory, expected a file name", name)
+	}
+
+	rel := filepath.Clean(strings.TrimLeft(filepath.FromSlash(name), string(filepath.Separator)))
+	if rel == "." {
+		return nil, fmt.Errorf("output name %q is a directory, expected a file name", name)
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("output name %q escapes out_dir %q", name, outdirs[0])
+	}
+
+	paths := make([]string, 0, len(outdirs))
+	for _, outdir := range outdirs {
+		paths = append(paths, fmt.Sprintf("%s/%s", outdir, name))
+	}
+	return paths, nil
+}
+
+// Locate returns the download URL of f and f with file_name filled in. The
+// url and file_name are joined by exactly one slash. An http(s) file_name is
+// used as the URL as-is, and a file without file_name downloads the
+// repository url itself; both take their name from the last element of the
+// URL path.
+func (r Repositories) Locate(f File) (string, File, error) {
+	var u string
+	switch {
+	case IsRemote(f.FileName):
+		u = f.FileName
+	case "" != f.FileName:
+		return strings.TrimRight(r.Url, "/") + "/" + strings.TrimLeft(f.FileName, "/"), f, nil
+	default:
+		u = r.Url
+	}
+	f.FileName = NameFromURL(u)
+	if "" == f.FileName && "" == f.Rename {
+		return u, f, fmt.Errorf("cannot derive a file name from %q, set file_name or rename", u)
+	}
+	return u, f, nil
+}
+
+// IsRemote reports whether s is an http or https URL.
+func IsRemote(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// NameFromURL returns the last element of the path of rawurl, ignoring any
+// query or fragment. It returns "" when the path has no file name.
+func NameFromURL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || strings.HasSuffix(u.Path, "/") {
+		return ""
+	}
+	name := path.Base(u.Path)
+	if "." == name || "/" == name {
+		return ""
+	}
+	return name
+}
+
+// Rebase places path under root and rejects the result when it, or the
+// nearest existing parent after resolving symlinks, lies outside root.
+func Rebase(root string, path string) (string, error) {
+	joined := filepath.Join(root, path)
+	if !within(filepath.Clean(root), joined) {
+		return "", fmt.Errorf("path %q escapes output root %q", path, root)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		// nothing under a missing root can point elsewhere yet
+		return joined, nil
+	}
+	for dir := filepath.Dir(joined); ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			if !within(realRoot, real) {
+				return "", fmt.Errorf("path %q escapes output root %q through a symlink", path, root)
+			}
+			break
+		}
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	return joined, nil
+}
+
+func within(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// RequireVersion fails when the manifest's min_version is newer than
+// running. Development builds reporting 0.0.0 are not checked.
+func (fd FileData) RequireVersion(running string) error {
+	if "" == fd.MinVersion {
+		return nil
+	}
+	min, err := version.Parse(fd.MinVersion)
+	if err != nil {
+		return fmt.Errorf("min_version: %w", err)
+	}
+	cur, err := version.Parse(running)
+	if err != nil || cur == (version.Version{}) {
+		return nil
+	}
+	if version.Compare(cur, min) < 0 {
+		return fmt.Errorf("manifest requires ppkgmgr %s or newer, running %s", min, cur)
+	}
+	return nil
+}
+
+// Validate checks every file entry of the manifest.
+func (fd FileData) Validate() error {
+	for _, repo := range fd.Repo {
+		for _, fs := range repo.Files {
+			_, fs, err := repo.Locate(fs)
+			if err != nil {
+				return err
+			}
+			if _, err := fs.ResolvePath(ResolveOptions{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LocalPath converts a file:// URL into a filesystem path. Other values are
+// returned unchanged.
+func LocalPath(src string) string {
+	if !strings.HasPrefix(src, "file://") {
+		return src
+	}
+	u, err := url.Parse(src)
+	if err != nil || (u.Host != "" && u.Host != "localhost") {
+		return strings.TrimPrefix(src, "file://")
+	}
+	return u.Path
+}
+
+// LoadRaw returns the manifest bytes at path, reading stdin when path is "-".
+func LoadRaw(path string) ([]byte, error) {
+	if "-" == path {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(LocalPath(path))
+}
+
+// FormatOf picks the manifest format from the extension of path, falling
+// back to YAML when it is not recognised.
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatYAML
 }
 
 func Parse(path string) FileData {
+	raw, err := LoadRaw(path)
+	if err != nil {
+		return FileData{}
+	}
+
+	fd, _ := ParseFormat(bytes.NewReader(raw), FormatOf(path))
+	fd, _ = ResolveIncludes(fd, path)
+
+	return fd
+}
+
+// ParseReader decodes a YAML manifest from r.
+func ParseReader(r io.Reader) (FileData, error) {
+	return ParseFormat(r, FormatYAML)
+}
+
+// ParseFormat decodes a manifest written in format from r.
+func ParseFormat(r io.Reader, format string) (FileData, error) {
 	var fd FileData
 
-	raw, err := ioutil.ReadFile(path)
+	raw, err := ioutil.ReadAll(r)
 	if err != nil {
-		return fd
+		return fd, err
 	}
 
-	err = yaml.Unmarshal(raw, &fd)
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(raw, &fd)
+	case FormatTOML:
+		err = toml.Unmarshal(raw, &fd)
+	default:
+		err = yaml.Unmarshal(raw, &fd)
+	}
+	if err != nil {
+		return fd, err
+	}
 
-	return fd
+	if err = fd.expandVars(); err != nil {
+		return fd, err
+	}
+	err = fd.expandPlatform()
+
+	return fd, err
 }
@@ -1,6 +1,9 @@
 package data
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -30,3 +33,381 @@ func TestDataParser_NotFoundFile(t *testing.T) {
 	}
 
 }
+
+func TestDataParser_Executable(t *testing.T) {
+
+	fd := Parse("../../test/data/testdata.yml")
+
+	if fd.Repo[0].Files[0].Executable {
+		t.Error("exp is false")
+	}
+	if !fd.Repo[1].Files[0].Executable {
+		t.Error("exp is true")
+	}
+
+}
+
+func TestFile_DirPerm(t *testing.T) {
+
+	perm, err := File{}.DirPerm()
+	if err != nil || perm != DefaultDirMode {
+		t.Errorf("exp is %o != %o", DefaultDirMode, perm)
+	}
+
+	perm, err = File{DirMode: "0700"}.DirPerm()
+	if err != nil || perm != 0700 {
+		t.Errorf("exp is 700 != %o", perm)
+	}
+
+	if _, err := (File{DirMode: "0788"}).DirPerm(); err == nil {
+		t.Error("exp is err")
+	}
+	if _, err := (File{DirMode: "01777"}).DirPerm(); err == nil {
+		t.Error("exp is err")
+	}
+
+}
+
+func TestFile_CheckSize(t *testing.T) {
+
+	if err := (File{}).CheckSize(10); err != nil {
+		t.Error("exp is nil")
+	}
+	if err := (File{Size: 10}).CheckSize(10); err != nil {
+		t.Error("exp is nil")
+	}
+	err := File{Size: 10}.CheckSize(3)
+	if err == nil || err.Error() != "size mismatch: expected 10 got 3" {
+		t.Errorf("unexpected err: %v", err)
+	}
+
+}
+
+func TestDataParser_Enabled(t *testing.T) {
+
+	fd, err := ParseReader(strings.NewReader(`
+repositories:
+  - url: https://example.com
+    files:
+      - file_name: a
+      - file_name: b
+        enabled: false
+      - file_name: c
+        enabled: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := fd.Repo[0].Files
+	if !files[0].IsEnabled() || files[1].IsEnabled() || !files[2].IsEnabled() {
+		t.Errorf("unexpected enabled %v %v %v", files[0].IsEnabled(), files[1].IsEnabled(), files[2].IsEnabled())
+	}
+
+}
+
+func TestDataParser_OutDirList(t *testing.T) {
+
+	cases := map[string]string{
+		FormatYAML: "repositories:\n  - url: u\n    files:\n      - file_name: a\n        out_dir: [./bin, ./copy]\n",
+		FormatJSON: `{"repositories": [{"url": "u", "files": [{"file_name": "a", "out_dir": ["./bin", "./copy"]}]}]}`,
+		FormatTOML: "[[repositories]]\nurl = \"u\"\n[[repositories.files]]\nfile_name = \"a\"\nout_dir = [\"./bin\", \"./copy\"]\n",
+	}
+	for format, in := range cases {
+		fd, err := ParseFormat(strings.NewReader(in), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		paths, err := fd.Repo[0].Files[0].ResolvePaths(ResolveOptions{})
+		if err != nil || len(paths) != 2 || paths[0] != "./bin/a" || paths[1] != "./copy/a" {
+			t.Errorf("%s: unexpected %v %v", format, paths, err)
+		}
+	}
+
+}
+
+func TestRepositories_Locate(t *testing.T) {
+
+	repo := Repositories{Url: "https://example.com/releases"}
+	u, fs, err := repo.Locate(File{FileName: "tool.tar.gz"})
+	if err != nil || u != "https://example.com/releases/tool.tar.gz" || fs.FileName != "tool.tar.gz" {
+		t.Errorf("unexpected %s %+v %v", u, fs, err)
+	}
+
+	for _, c := range [][2]string{{"https://example.com/", "index.html"}, {"https://example.com", "/index.html"}, {"https://example.com//", "//index.html"}} {
+		u, _, _ := Repositories{Url: c[0]}.Locate(File{FileName: c[1]})
+		if u != "https://example.com/index.html" {
+			t.Errorf("%s + %s: exp is https://example.com/index.html != %s", c[0], c[1], u)
+		}
+	}
+
+	repo = Repositories{Url: "https://example.com/dl/tool-1.0.zip?X-Signature=abc"}
+	u, fs, err = repo.Locate(File{OutDir: Dirs{"./bin"}})
+	if err != nil || u != repo.Url || fs.FileName != "tool-1.0.zip" {
+		t.Errorf("unexpected %s %+v %v", u, fs, err)
+	}
+	if p, _ := fs.ResolvePath(ResolveOptions{}); p != "./bin/tool-1.0.zip" {
+		t.Errorf("exp is ./bin/tool-1.0.zip != %s", p)
+	}
+
+	repo = Repositories{Url: "https://example.com/releases"}
+	u, fs, err = repo.Locate(File{FileName: "https://mirror.test/other/tool.gz"})
+	if err != nil || u != "https://mirror.test/other/tool.gz" || fs.FileName != "tool.gz" {
+		t.Errorf("unexpected %s %+v %v", u, fs, err)
+	}
+	u, fs, err = repo.Locate(File{FileName: "http://mirror.test/tool.gz", Rename: "bin/tool"})
+	if p, _ := fs.ResolvePath(ResolveOptions{}); err != nil || u != "http://mirror.test/tool.gz" || p != "./bin/tool" {
+		t.Errorf("unexpected %s %s %v", u, p, err)
+	}
+
+	repo = Repositories{Url: "https://example.com/"}
+	if _, _, err := repo.Locate(File{}); err == nil {
+		t.Error("exp is err")
+	}
+	if _, fs, err := repo.Locate(File{Rename: "index.html"}); err != nil || fs.Rename != "index.html" {
+		t.Errorf("unexpected %+v %v", fs, err)
+	}
+
+}
+
+func TestFile_MatchesPlatform(t *testing.T) {
+
+	if !(File{}).MatchesPlatform("linux", "amd64") {
+		t.Error("exp is true")
+	}
+	if !(File{OS: "linux", Arch: "amd64"}).MatchesPlatform("linux", "amd64") {
+		t.Error("exp is true")
+	}
+	if (File{OS: "darwin"}).MatchesPlatform("linux", "amd64") {
+		t.Error("exp is false")
+	}
+	if (File{OS: "linux", Arch: "arm64"}).MatchesPlatform("linux", "amd64") {
+		t.Error("exp is false")
+	}
+
+}
+
+func TestDataParser_Reader(t *testing.T) {
+
+	fd, err := ParseReader(strings.NewReader(`
+repositories:
+  - url: https://example.com
+    files:
+      - file_name: a.txt
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fd.Repo) != 1 || fd.Repo[0].Files[0].FileName != "a.txt" {
+		t.Error("exp is a.txt")
+	}
+
+	if _, err := ParseReader(strings.NewReader("repositories: [")); err == nil {
+		t.Error("exp is err")
+	}
+
+}
+
+func TestLocalPath(t *testing.T) {
+
+	cases := map[string]string{
+		"./config.yml":                  "./config.yml",
+		"file:///abs/path/config.yml":   "/abs/path/config.yml",
+		"file://localhost/abs/conf.yml": "/abs/conf.yml",
+		"file://rel/config.yml":         "rel/config.yml",
+	}
+	for in, exp := range cases {
+		if got := LocalPath(in); got != exp {
+			t.Errorf("exp is %s != %s", exp, got)
+		}
+	}
+
+}
+
+func TestDataParser_FileURL(t *testing.T) {
+
+	abs, _ := filepath.Abs("../../test/data/testdata.yml")
+	fd := Parse("file://" + filepath.ToSlash(abs))
+
+	if len(fd.Repo) != 2 {
+		t.Error("exp is 2")
+	}
+
+}
+
+func TestFile_ResolvePath(t *testing.T) {
+
+	p, err := File{FileName: "a.txt"}.ResolvePath(ResolveOptions{})
+	if err != nil || p != "./a.txt" {
+		t.Errorf("exp is ./a.txt != %s", p)
+	}
+
+	p, err = File{FileName: "a.txt", Rename: "b.txt", OutDir: Dirs{"./bin"}}.ResolvePath(ResolveOptions{})
+	if err != nil || p != "./bin/b.txt" {
+		t.Errorf("exp is ./bin/b.txt != %s", p)
+	}
+
+	if _, err := (File{FileName: "a.txt", Rename: "sub/../b.txt", OutDir: Dirs{"./bin"}}).ResolvePath(ResolveOptions{}); err != nil {
+		t.Errorf("exp is nil: %v", err)
+	}
+
+}
+
+func TestFile_ResolvePath_Subdir(t *testing.T) {
+
+	p, err := File{FileName: "a.txt", Rename: "sub/tool", OutDir: Dirs{"./bin"}}.ResolvePath(ResolveOptions{})
+	if err != nil || p != "./bin/sub/tool" {
+		t.Errorf("exp is ./bin/sub/tool != %s", p)
+	}
+
+	for _, rename := range []string{"sub/", ".", "sub/.."} {
+		if _, err := (File{FileName: "a.txt", Rename: rename, OutDir: Dirs{"./bin"}}).ResolvePath(ResolveOptions{}); err == nil {
+			t.Errorf("%s: exp is err", rename)
+		}
+	}
+
+}
+
+func TestFile_ResolvePath_Base(t *testing.T) {
+
+	p, err := File{FileName: "a.txt", OutDir: Dirs{"./bin"}}.ResolvePath(ResolveOptions{Base: "/etc/manifests"})
+	if err != nil || p != filepath.Join("/etc/manifests", "bin")+"/a.txt" {
+		t.Errorf("unexpected %s", p)
+	}
+
+	abs, _ := filepath.Abs("/opt/bin")
+	p, err = File{FileName: "a.txt", OutDir: Dirs{abs}}.ResolvePath(ResolveOptions{Base: "/etc/manifests"})
+	if err != nil || p != abs+"/a.txt" {
+		t.Errorf("unexpected %s", p)
+	}
+
+}
+
+func TestFile_ResolvePath_Dest(t *testing.T) {
+
+	fs := File{FileName: "a.txt", Rename: "sub/b.txt", OutDir: Dirs{"./bin", "./copy"}}
+	paths, err := fs.ResolvePaths(ResolveOptions{Base: "/etc/manifests", Dest: "/tmp/stage"})
+	if err != nil || len(paths) != 1 || paths[0] != "/tmp/stage/sub/b.txt" {
+		t.Errorf("exp is /tmp/stage/sub/b.txt != %v (%v)", paths, err)
+	}
+
+	fs = File{FileName: "a.txt", Rename: "../b.txt"}
+	if _, err := fs.ResolvePaths(ResolveOptions{Dest: "/tmp/stage"}); err == nil {
+		t.Error("exp is traversal err")
+	}
+
+}
+
+func TestFile_ResolvePath_Traversal(t *testing.T) {
+
+	if _, err := (File{FileName: "a.txt", Rename: "../../etc/thing", OutDir: Dirs{"./bin"}}).ResolvePath(ResolveOptions{}); err == nil {
+		t.Error("exp is err")
+	}
+	if _, err := (File{FileName: "sub/../../a.txt", OutDir: Dirs{"./bin"}}).ResolvePath(ResolveOptions{}); err == nil {
+		t.Error("exp is err")
+	}
+	if _, err := (File{FileName: "a.txt", Rename: ".."}).ResolvePath(ResolveOptions{}); err == nil {
+		t.Error("exp is err")
+	}
+
+	fd := FileData{Repo: []Repositories{{Files: []File{{FileName: "a.txt", Rename: "../a.txt"}}}}}
+	if err := fd.Validate(); err == nil {
+		t.Error("exp is err")
+	}
+
+}
+
+func TestRebase(t *testing.T) {
+
+	root := t.TempDir()
+
+	p, err := Rebase(root, "./bin/tool")
+	if err != nil || p != filepath.Join(root, "bin", "tool") {
+		t.Errorf("unexpected %s %v", p, err)
+	}
+
+	p, err = Rebase(root, "/usr/local/bin/tool")
+	if err != nil || p != filepath.Join(root, "usr", "local", "bin", "tool") {
+		t.Errorf("unexpected %s %v", p, err)
+	}
+
+	if _, err := Rebase(root, "../outside"); err == nil {
+		t.Error("exp is err")
+	}
+
+}
+
+func TestRebase_Symlink(t *testing.T) {
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skip(err)
+	}
+
+	if _, err := Rebase(root, "link/tool"); err == nil {
+		t.Error("exp is err")
+	}
+	if _, err := Rebase(root, "link/sub/tool"); err == nil {
+		t.Error("exp is err")
+	}
+
+}
+
+func TestDataParser_Formats(t *testing.T) {
+
+	for _, path := range []string{
+		"../../test/data/testdata.yml",
+		"../../test/data/testdata.json",
+		"../../test/data/testdata.toml",
+	} {
+		fd := Parse(path)
+		if len(fd.Repo) != 2 {
+			t.Errorf("%s: exp is 2", path)
+			continue
+		}
+		if fd.Repo[0].Files[1].FileName != "300.jpg" || !fd.Repo[1].Files[0].Executable {
+			t.Errorf("%s: unexpected %+v", path, fd)
+		}
+	}
+
+}
+
+func TestFormatOf(t *testing.T) {
+
+	cases := map[string]string{
+		"a.yml":  FormatYAML,
+		"a.yaml": FormatYAML,
+		"a.JSON": FormatJSON,
+		"a.toml": FormatTOML,
+		"-":      FormatYAML,
+	}
+	for in, exp := range cases {
+		if got := FormatOf(in); got != exp {
+			t.Errorf("%s: exp is %s != %s", in, exp, got)
+		}
+	}
+
+}
+
+func TestFileData_RequireVersion(t *testing.T) {
+
+	fd := FileData{MinVersion: "0.3.0"}
+
+	if err := fd.RequireVersion("0.2.0"); err == nil {
+		t.Error("exp is err")
+	}
+	if err := fd.RequireVersion("0.3.0"); err != nil {
+		t.Errorf("exp is nil: %v", err)
+	}
+	if err := fd.RequireVersion("0.0.0"); err != nil {
+		t.Errorf("exp is nil for dev build: %v", err)
+	}
+	if err := (FileData{}).RequireVersion("0.1.0"); err != nil {
+		t.Errorf("exp is nil: %v", err)
+	}
+	if err := (FileData{MinVersion: "latest"}).RequireVersion("0.1.0"); err == nil {
+		t.Error("exp is err")
+	}
+
+}
@@ -0,0 +1,64 @@
+package data
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Filter selects manifest files by glob patterns matched against the
+// file_name, its base name or the resolved output path.
+type Filter struct {
+	Only []string
+	Skip []string
+}
+
+// NewFilter splits comma-separated patterns and rejects malformed ones.
+func NewFilter(only []string, skip []string) (Filter, error) {
+	var f Filter
+	var err error
+	if f.Only, err = splitPatterns(only); err != nil {
+		return f, err
+	}
+	if f.Skip, err = splitPatterns(skip); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+func splitPatterns(values []string) ([]string, error) {
+	var patterns []string
+	for _, v := range values {
+		for _, p := range strings.Split(v, ",") {
+			p = strings.TrimSpace(p)
+			if "" == p {
+				continue
+			}
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, err
+			}
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns, nil
+}
+
+// Allow reports whether fs, resolved to outPath, passes the filter.
+func (f Filter) Allow(fs File, outPath string) bool {
+	names := []string{fs.FileName, path.Base(fs.FileName), filepath.ToSlash(filepath.Clean(outPath))}
+	if len(f.Only) > 0 && !matchAny(f.Only, names) {
+		return false
+	}
+	return !matchAny(f.Skip, names)
+}
+
+func matchAny(patterns []string, names []string) bool {
+	for _, p := range patterns {
+		for _, n := range names {
+			if ok, _ := path.Match(p, n); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
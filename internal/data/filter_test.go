@@ -0,0 +1,59 @@
+package data
+
+import (
+	"testing"
+)
+
+func TestFilter_Allow(t *testing.T) {
+
+	f, err := NewFilter([]string{"*.jpg,tool"}, []string{"300.*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		file File
+		out  string
+		exp  bool
+	}{
+		{File{FileName: "200.jpg"}, "./photos/200.jpg", true},
+		{File{FileName: "300.jpg"}, "./photos/300.jpg", false},
+		{File{FileName: "100.webp"}, "./photos/100.webp", false},
+		{File{FileName: "dist/tool"}, "./bin/tool", true},
+	}
+	for _, c := range cases {
+		if got := f.Allow(c.file, c.out); got != c.exp {
+			t.Errorf("%s: exp is %v", c.file.FileName, c.exp)
+		}
+	}
+
+}
+
+func TestFilter_OutputPath(t *testing.T) {
+
+	f, _ := NewFilter([]string{"bin/*"}, nil)
+
+	if !f.Allow(File{FileName: "tool"}, "./bin/tool") {
+		t.Error("exp is true")
+	}
+	if f.Allow(File{FileName: "tool"}, "./lib/tool") {
+		t.Error("exp is false")
+	}
+
+}
+
+func TestFilter_Empty(t *testing.T) {
+
+	if !(Filter{}).Allow(File{FileName: "a"}, "./a") {
+		t.Error("exp is true")
+	}
+
+}
+
+func TestNewFilter_BadPattern(t *testing.T) {
+
+	if _, err := NewFilter([]string{"[a"}, nil); err == nil {
+		t.Error("exp is err")
+	}
+
+}
@@ -0,0 +1,99 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"ppkgmgr/internal/version"
+)
+
+// ResolveIncludes appends the repositories of every manifest listed under
+// include to fd. Relative includes are resolved against the directory of
+// path, the manifest fd was read from ("-" for stdin resolves against the
+// working directory). A manifest reached through several includes is merged
+// only once.
+func ResolveIncludes(fd FileData, path string) (FileData, error) {
+	return resolveIncludes(fd, path, []string{}, map[string]bool{})
+}
+
+func resolveIncludes(fd FileData, path string, stack []string, merged map[string]bool) (FileData, error) {
+	if len(fd.Include) == 0 {
+		return fd, nil
+	}
+
+	if "-" != path {
+		abs, err := filepath.Abs(LocalPath(path))
+		if err != nil {
+			return fd, err
+		}
+		stack = append(stack, abs)
+	}
+
+	base := "."
+	if "-" != path {
+		base = filepath.Dir(LocalPath(path))
+	}
+
+	includes := fd.Include
+	fd.Include = nil
+	for _, inc := range includes {
+		if IsRemote(inc) {
+			return fd, fmt.Errorf("include %s: remote manifests are not supported", inc)
+		}
+
+		child := LocalPath(inc)
+		if !filepath.IsAbs(child) {
+			child = filepath.Join(base, child)
+		}
+		abs, err := filepath.Abs(child)
+		if err != nil {
+			return fd, err
+		}
+		for _, seen := range stack {
+			if seen == abs {
+				return fd, fmt.Errorf("include %s: cycle detected", inc)
+			}
+		}
+		if merged[abs] {
+			continue
+		}
+		merged[abs] = true
+
+		raw, err := LoadRaw(child)
+		if err != nil {
+			return fd, fmt.Errorf("include %s: %w", inc, err)
+		}
+		sub, err := ParseFormat(bytes.NewReader(raw), FormatOf(child))
+		if err != nil {
+			return fd, fmt.Errorf("include %s: %w", inc, err)
+		}
+		sub, err = resolveIncludes(sub, child, stack, merged)
+		if err != nil {
+			return fd, err
+		}
+		fd.Repo = append(fd.Repo, sub.Repo...)
+		fd.MinVersion = newerVersion(fd.MinVersion, sub.MinVersion)
+	}
+
+	return fd, nil
+}
+
+// newerVersion keeps the stricter of two min_version values. Unparsable
+// values win so that RequireVersion reports them.
+func newerVersion(a string, b string) string {
+	if "" == a {
+		return b
+	}
+	if "" == b {
+		return a
+	}
+	va, err := version.Parse(a)
+	if err != nil {
+		return a
+	}
+	vb, err := version.Parse(b)
+	if err != nil || version.Compare(vb, va) > 0 {
+		return b
+	}
+	return a
+}
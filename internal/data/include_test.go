@@ -0,0 +1,61 @@
+package data
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDataParser_Include(t *testing.T) {
+
+	fd := Parse("../../test/data/include/main.yml")
+
+	var comments []string
+	for _, repo := range fd.Repo {
+		comments = append(comments, repo.Comment)
+	}
+	exp := []string{"main", "tools", "more"}
+	if len(comments) != len(exp) {
+		t.Fatalf("exp is %v != %v", exp, comments)
+	}
+	for i := range exp {
+		if comments[i] != exp[i] {
+			t.Errorf("exp is %v != %v", exp, comments)
+		}
+	}
+	if len(fd.Include) != 0 {
+		t.Error("exp is resolved")
+	}
+
+}
+
+func TestResolveIncludes_Cycle(t *testing.T) {
+
+	path := "../../test/data/include/cycle_a.yml"
+	raw, _ := LoadRaw(path)
+	fd, _ := ParseReader(bytes.NewReader(raw))
+
+	if _, err := ResolveIncludes(fd, path); err == nil {
+		t.Error("exp is cycle err")
+	}
+
+}
+
+func TestResolveIncludes_Remote(t *testing.T) {
+
+	fd := FileData{Include: []string{"https://example.com/m.yml"}}
+	if _, err := ResolveIncludes(fd, "-"); err == nil {
+		t.Error("exp is err")
+	}
+
+}
+
+func TestNewerVersion(t *testing.T) {
+
+	if got := newerVersion("0.2.0", "0.10.0"); got != "0.10.0" {
+		t.Errorf("exp is 0.10.0 != %s", got)
+	}
+	if got := newerVersion("1.0.0", ""); got != "1.0.0" {
+		t.Errorf("exp is 1.0.0 != %s", got)
+	}
+
+}
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Template rewrites the last element of an output name. It understands
+// {name} (the whole file name), {base} (without its extension) and {ext}
+// (the extension including the dot).
+type Template struct {
+	raw string
+}
+
+var templateTokens = []string{"{name}", "{base}", "{ext}"}
+
+// ParseTemplate validates s. An empty template leaves names unchanged.
+func ParseTemplate(s string) (Template, error) {
+	rest := s
+	for _, tok := range templateTokens {
+		rest = strings.ReplaceAll(rest, tok, "")
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return Template{}, fmt.Errorf("invalid output template %q: supported tokens are {name}, {base} and {ext}", s)
+	}
+	if strings.ContainsAny(s, `/\`) {
+		return Template{}, fmt.Errorf("invalid output template %q: must not contain path separators", s)
+	}
+	return Template{raw: s}, nil
+}
+
+// Apply returns name with its last element rewritten by the template.
+func (t Template) Apply(name string) string {
+	if "" == t.raw {
+		return name
+	}
+	dir, file := path.Split(name)
+	ext := path.Ext(file)
+	r := strings.NewReplacer(
+		"{name}", file,
+		"{base}", strings.TrimSuffix(file, ext),
+		"{ext}", ext,
+	)
+	return dir + r.Replace(t.raw)
+}
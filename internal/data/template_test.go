@@ -0,0 +1,63 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplate_Apply(t *testing.T) {
+
+	cases := []struct {
+		tmpl, in, exp string
+	}{
+		{"", "tool-1.2.gz", "tool-1.2.gz"},
+		{"{base}", "tool.gz", "tool"},
+		{"{base}", "sub/tool.gz", "sub/tool"},
+		{"renamed{ext}", "tool.gz", "renamed.gz"},
+		{"{name}.bin", "tool", "tool.bin"},
+	}
+	for _, c := range cases {
+		tmpl, err := ParseTemplate(c.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tmpl.Apply(c.in); got != c.exp {
+			t.Errorf("%s(%s): exp is %s != %s", c.tmpl, c.in, c.exp, got)
+		}
+	}
+
+}
+
+func TestParseTemplate_Invalid(t *testing.T) {
+
+	for _, in := range []string{"{version}", "{base", "base}", "dir/{name}"} {
+		if _, err := ParseTemplate(in); err == nil {
+			t.Errorf("%s: exp is err", in)
+		}
+	}
+
+}
+
+func TestFile_ResolvePath_Template(t *testing.T) {
+
+	tmpl, _ := ParseTemplate("{base}")
+
+	p, err := File{FileName: "tool.tar", OutDir: Dirs{"./bin"}}.ResolvePath(ResolveOptions{Template: tmpl})
+	if err != nil || p != "./bin/tool" {
+		t.Errorf("exp is ./bin/tool != %s", p)
+	}
+
+	for _, c := range [][2]string{{"{base}", ".bashrc"}, {"{ext}", "tool"}, {"{base}", "sub/.bashrc"}} {
+		tmpl, _ := ParseTemplate(c[0])
+		_, err := File{FileName: c[1], OutDir: Dirs{"./bin"}}.ResolvePath(ResolveOptions{Template: tmpl})
+		if err == nil || !strings.Contains(err.Error(), "output name is empty") {
+			t.Errorf("%s(%s): exp is empty name err: %v", c[0], c[1], err)
+		}
+	}
+
+	tmpl, _ = ParseTemplate("..")
+	if _, err := (File{FileName: "tool", OutDir: Dirs{"./bin"}}).ResolvePath(ResolveOptions{Template: tmpl}); err == nil {
+		t.Error("exp is traversal err")
+	}
+
+}
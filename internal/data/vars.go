@@ -0,0 +1,101 @@
+package data
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var placeholder = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// expand replaces every ${name} in s that lookup knows about. Placeholders
+// lookup does not handle are left untouched; lookup errors are returned.
+func expand(s string, lookup func(name string) (string, bool, error)) (string, error) {
+	var firstErr error
+	out := placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		name := placeholder.FindStringSubmatch(m)[1]
+		v, ok, err := lookup(name)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if !ok {
+			return m
+		}
+		return v
+	})
+	return out, firstErr
+}
+
+// expandFields applies expand to every templated field of the manifest.
+func (fd *FileData) expandFields(lookup func(name string) (string, bool, error)) error {
+	var err error
+	for i := range fd.Repo {
+		repo := &fd.Repo[i]
+		for _, p := range []*string{&repo.Url, &repo.ChecksumsURL} {
+			if *p, err = expand(*p, lookup); err != nil {
+				return err
+			}
+		}
+		for j := range repo.Files {
+			fs := &repo.Files[j]
+			for _, p := range []*string{&fs.FileName, &fs.Rename} {
+				if *p, err = expand(*p, lookup); err != nil {
+					return err
+				}
+			}
+			for k := range fs.OutDir {
+				if fs.OutDir[k], err = expand(fs.OutDir[k], lookup); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// expandVars substitutes ${var.NAME} with the manifest's vars.
+func (fd *FileData) expandVars() error {
+	return fd.expandFields(func(name string) (string, bool, error) {
+		if !strings.HasPrefix(name, "var.") {
+			return "", false, nil
+		}
+		key := strings.TrimPrefix(name, "var.")
+		v, ok := fd.Vars[key]
+		if !ok {
+			return "", false, fmt.Errorf("undefined variable %q", name)
+		}
+		return v, true, nil
+	})
+}
+
+// machineNames maps GOARCH values to the names used by uname -m, which many
+// release assets embed instead.
+var machineNames = map[string]string{
+	"386":   "i386",
+	"amd64": "x86_64",
+	"arm64": "aarch64",
+}
+
+// expandPlatform substitutes ${os} and ${arch} with runtime.GOOS and
+// runtime.GOARCH, and ${machine} with the uname -m style name of the arch.
+func (fd *FileData) expandPlatform() error {
+	return fd.expandFields(func(name string) (string, bool, error) {
+		switch name {
+		case "os":
+			return runtime.GOOS, true, nil
+		case "arch":
+			return runtime.GOARCH, true, nil
+		case "machine":
+			return defaultMachine(runtime.GOARCH), true, nil
+		}
+		return "", false, nil
+	})
+}
+
+func defaultMachine(goarch string) string {
+	if m, ok := machineNames[goarch]; ok {
+		return m
+	}
+	return goarch
+}
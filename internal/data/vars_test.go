@@ -0,0 +1,95 @@
+package data
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDataParser_Vars(t *testing.T) {
+
+	fd, err := ParseReader(strings.NewReader(`
+vars:
+  version: 1.2.3
+  host: https://example.com
+repositories:
+  - url: ${var.host}/releases/v${var.version}
+    files:
+      - file_name: tool-${var.version}.tar.gz
+        out_dir: ./tools/${var.version}
+        rename: tool-${HOME}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := fd.Repo[0]
+	if repo.Url != "https://example.com/releases/v1.2.3" {
+		t.Errorf("unexpected url %s", repo.Url)
+	}
+	fs := repo.Files[0]
+	if fs.FileName != "tool-1.2.3.tar.gz" || fs.OutDir[0] != "./tools/1.2.3" {
+		t.Errorf("unexpected %+v", fs)
+	}
+	if fs.Rename != "tool-${HOME}" {
+		t.Errorf("exp is untouched %s", fs.Rename)
+	}
+
+}
+
+func TestDataParser_UndefinedVar(t *testing.T) {
+
+	_, err := ParseReader(strings.NewReader(`
+repositories:
+  - url: https://example.com
+    files:
+      - file_name: tool-${var.version}
+`))
+	if err == nil || !strings.Contains(err.Error(), `undefined variable "var.version"`) {
+		t.Errorf("exp is undefined err: %v", err)
+	}
+
+}
+
+func TestDataParser_Platform(t *testing.T) {
+
+	fd, err := ParseReader(strings.NewReader(`
+vars:
+  target: ${os}-${arch}
+repositories:
+  - url: https://example.com/${os}
+    files:
+      - file_name: tool-${var.target}.tar.gz
+        rename: tool-${machine}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := fd.Repo[0]
+	if repo.Url != "https://example.com/"+runtime.GOOS {
+		t.Errorf("unexpected url %s", repo.Url)
+	}
+	fs := repo.Files[0]
+	if fs.FileName != "tool-"+runtime.GOOS+"-"+runtime.GOARCH+".tar.gz" {
+		t.Errorf("unexpected file_name %s", fs.FileName)
+	}
+	if fs.Rename != "tool-"+defaultMachine(runtime.GOARCH) {
+		t.Errorf("unexpected rename %s", fs.Rename)
+	}
+
+}
+
+func TestDefaultMachine(t *testing.T) {
+
+	if m := defaultMachine("amd64"); m != "x86_64" {
+		t.Errorf("exp is x86_64 != %s", m)
+	}
+	if m := defaultMachine("arm64"); m != "aarch64" {
+		t.Errorf("exp is aarch64 != %s", m)
+	}
+	if m := defaultMachine("riscv64"); m != "riscv64" {
+		t.Errorf("exp is riscv64 != %s", m)
+	}
+
+}
@@ -0,0 +1,95 @@
+package digest
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var ErrEmptyDigest = errors.New("expected digest is empty")
+
+// Sum returns the hex-encoded SHA-256 digest of data.
+func Sum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyBytes hashes data and compares it with expected. It returns whether
+// they match along with the computed digest.
+func VerifyBytes(data []byte, expected string) (bool, string, error) {
+	actual := Sum(data)
+	ok, err := compare(expected, actual)
+	return ok, actual, err
+}
+
+// SumFile returns the hex-encoded SHA-256 digest of the file at path.
+func SumFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Verify hashes the file at path and compares it with expected.
+func Verify(path string, expected string) (bool, string, error) {
+	actual, err := SumFile(path)
+	if err != nil {
+		return false, "", err
+	}
+
+	ok, err := compare(expected, actual)
+	return ok, actual, err
+}
+
+// SidecarSuffix names the digest file written next to an output.
+const SidecarSuffix = ".sha256"
+
+// WriteSidecar stores digest in path+SidecarSuffix without a trailing newline.
+func WriteSidecar(path string, digest string) error {
+	return os.WriteFile(path+SidecarSuffix, []byte(strings.TrimSpace(digest)), 0644)
+}
+
+func compare(expected string, actual string) (bool, error) {
+	expected = strings.TrimSpace(expected)
+	if "" == expected {
+		return false, ErrEmptyDigest
+	}
+	return strings.EqualFold(expected, actual), nil
+}
+
+// ParseSums reads a SHA256SUMS style listing of "<hex>  <filename>" lines,
+// accepting the binary-mode "*" marker before the name.
+func ParseSums(r io.Reader) (map[string]string, error) {
+	sums := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if "" == line || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("checksum line %d: expected \"<hex>  <filename>\"", n)
+		}
+		name := strings.TrimPrefix(strings.TrimLeft(fields[1], " "), "*")
+		if "" == name {
+			return nil, fmt.Errorf("checksum line %d: missing filename", n)
+		}
+		sums[name] = fields[0]
+	}
+
+	return sums, scanner.Err()
+}
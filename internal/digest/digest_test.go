@@ -0,0 +1,86 @@
+package digest
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+const helloDigest = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func TestVerifyBytes(t *testing.T) {
+
+	ok, actual, err := VerifyBytes([]byte("hello"), " "+strings.ToUpper(helloDigest)+"\n")
+	if err != nil || !ok {
+		t.Errorf("exp is match: %v %v", ok, err)
+	}
+	if actual != helloDigest {
+		t.Errorf("exp is %s != %s", helloDigest, actual)
+	}
+
+	ok, _, err = VerifyBytes([]byte("hello!"), helloDigest)
+	if err != nil || ok {
+		t.Error("exp is mismatch")
+	}
+
+	if _, _, err := VerifyBytes([]byte("hello"), " "); err != ErrEmptyDigest {
+		t.Error("exp is ErrEmptyDigest")
+	}
+
+}
+
+func TestVerify(t *testing.T) {
+
+	tmpFile, _ := ioutil.TempFile("", "tmpfile")
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString("hello")
+	tmpFile.Close()
+
+	ok, actual, err := Verify(tmpFile.Name(), helloDigest)
+	if err != nil || !ok || actual != helloDigest {
+		t.Errorf("exp is match: %v %s %v", ok, actual, err)
+	}
+
+	if _, _, err := Verify(tmpFile.Name()+".notfound", helloDigest); err == nil {
+		t.Error("exp is err")
+	}
+
+}
+
+func TestParseSums(t *testing.T) {
+
+	sums, err := ParseSums(strings.NewReader(helloDigest + "  tool-linux.tar.gz\n" +
+		"\n" +
+		"abcdef *tool-windows.zip\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sums["tool-linux.tar.gz"] != helloDigest {
+		t.Errorf("exp is %s != %s", helloDigest, sums["tool-linux.tar.gz"])
+	}
+	if sums["tool-windows.zip"] != "abcdef" {
+		t.Errorf("exp is abcdef != %s", sums["tool-windows.zip"])
+	}
+
+	if _, err := ParseSums(strings.NewReader("nofilename\n")); err == nil {
+		t.Error("exp is err")
+	}
+
+}
+
+func TestWriteSidecar(t *testing.T) {
+
+	path := t.TempDir() + "/tool"
+
+	if err := WriteSidecar(path, helloDigest+"\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path + SidecarSuffix)
+	if string(data) != helloDigest {
+		t.Errorf("exp is %q != %q", helloDigest, data)
+	}
+
+}
@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Output formats accepted by the -log-format flag.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Logger writes informational and error messages, dropping the
+// informational ones when Quiet is set.
+type Logger struct {
+	Out    io.Writer
+	Quiet  bool
+	Format string
+}
+
+// Event is a single significant occurrence, emitted as one JSON object
+// per line when the JSON format is selected.
+type Event struct {
+	Event   string `json:"event"`
+	URL     string `json:"url,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Bytes   int64  `json:"bytes,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Std is the logger shared by the command and its packages.
+var Std = &Logger{}
+
+// ValidFormat reports whether f is a supported log format.
+func ValidFormat(f string) bool {
+	return f == FormatText || f == FormatJSON
+}
+
+func (l *Logger) out() io.Writer {
+	if l.Out == nil {
+		return os.Stdout
+	}
+	return l.Out
+}
+
+func (l *Logger) write(e Event, format string, a ...interface{}) {
+	if l.Format != FormatJSON {
+		if format != "" {
+			fmt.Fprintf(l.out(), format, a...)
+		}
+		return
+	}
+	if e.Message == "" && format != "" {
+		e.Message = strings.TrimSpace(fmt.Sprintf(format, a...))
+	}
+	enc := json.NewEncoder(l.out())
+	enc.SetEscapeHTML(false)
+	enc.Encode(e)
+}
+
+// Info emits an informational event. In text mode the formatted message
+// is printed and an empty format prints nothing.
+func (l *Logger) Info(e Event, format string, a ...interface{}) {
+	if l.Quiet {
+		return
+	}
+	l.write(e, format, a...)
+}
+
+// Error emits an error event; it is never suppressed.
+func (l *Logger) Error(e Event, format string, a ...interface{}) {
+	if e.Event == "" {
+		e.Event = "error"
+	}
+	l.write(e, format, a...)
+}
+
+func (l *Logger) Infof(format string, a ...interface{}) {
+	l.Info(Event{Event: "info"}, format, a...)
+}
+
+func (l *Logger) Errorf(format string, a ...interface{}) {
+	l.Error(Event{}, format, a...)
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLogger_Quiet(t *testing.T) {
+
+	var buf bytes.Buffer
+	l := &Logger{Out: &buf, Quiet: true}
+
+	l.Infof("info %d\n", 1)
+	l.Errorf("Err: %s\n", "x")
+
+	if buf.String() != "Err: x\n" {
+		t.Errorf("exp is error only: %q", buf.String())
+	}
+
+}
+
+func TestLogger_Verbose(t *testing.T) {
+
+	var buf bytes.Buffer
+	l := &Logger{Out: &buf}
+
+	l.Infof("info %d\n", 1)
+	l.Errorf("Err: %s\n", "x")
+
+	if buf.String() != "info 1\nErr: x\n" {
+		t.Errorf("exp is both: %q", buf.String())
+	}
+
+}
+
+func TestLogger_JSON(t *testing.T) {
+
+	var buf bytes.Buffer
+	l := &Logger{Out: &buf, Format: FormatJSON}
+
+	l.Info(Event{Event: "download_finished", URL: "http://x/a", Path: "./a", Bytes: 3}, "downloaded: %s => %s\n", "http://x/a", "./a")
+	l.Errorf("Err: %s\n", "boom")
+
+	exp := `{"event":"download_finished","url":"http://x/a","path":"./a","bytes":3,"message":"downloaded: http://x/a => ./a"}` + "\n" +
+		`{"event":"error","message":"Err: boom"}` + "\n"
+	if buf.String() != exp {
+		t.Errorf("exp is %q != %q", exp, buf.String())
+	}
+
+}
@@ -0,0 +1,108 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version. Build metadata is ignored.
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 string
+}
+
+// Parse reads versions such as "1.2.3", "v1.2" or "1.2.3-rc.1".
+func Parse(s string) (Version, error) {
+	var v Version
+
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.Index(str, "+"); i >= 0 {
+		str = str[:i]
+	}
+	if i := strings.Index(str, "-"); i >= 0 {
+		v.Pre = str[i+1:]
+		str = str[:i]
+	}
+
+	parts := strings.Split(str, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 when a is older than, equal to or newer than b.
+// A pre-release sorts before the release it precedes.
+func Compare(a Version, b Version) int {
+	for _, d := range [][2]int{{a.Major, b.Major}, {a.Minor, b.Minor}, {a.Patch, b.Patch}} {
+		if d[0] != d[1] {
+			if d[0] < d[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case a.Pre == b.Pre:
+		return 0
+	case "" == a.Pre:
+		return 1
+	case "" == b.Pre:
+		return -1
+	}
+	return comparePre(a.Pre, b.Pre)
+}
+
+// comparePre orders dot-separated pre-release identifiers as semver does:
+// numeric identifiers compare numerically and sort before alphanumeric
+// ones, and a shorter list sorts first when it is a prefix of the other.
+func comparePre(a string, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.ParseUint(as[i], 10, 64)
+		bn, berr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aerr == nil && berr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aerr == nil:
+			return -1
+		case berr == nil:
+			return 1
+		case as[i] != bs[i]:
+			if as[i] < bs[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if "" != v.Pre {
+		s += "-" + v.Pre
+	}
+	return s
+}
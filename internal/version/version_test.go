@@ -0,0 +1,53 @@
+package version
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	cases := map[string]Version{
+		"1.2.3":          {1, 2, 3, ""},
+		"v0.2.0":         {0, 2, 0, ""},
+		"1.2":            {1, 2, 0, ""},
+		"1.2.3-rc.1+abc": {1, 2, 3, "rc.1"},
+	}
+	for in, exp := range cases {
+		if got, err := Parse(in); err != nil || got != exp {
+			t.Errorf("%s: exp is %v != %v (%v)", in, exp, got, err)
+		}
+	}
+
+	for _, in := range []string{"", "v", "1.x", "1.2.3.4"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("%s: exp is err", in)
+		}
+	}
+
+}
+
+func TestCompare(t *testing.T) {
+
+	cases := []struct {
+		a, b string
+		exp  int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-beta", "1.0.0-alpha", 1},
+		{"1.0.0-rc.10", "1.0.0-rc.9", 1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-rc.1", "1.0.0-rc.1", 0},
+	}
+	for _, c := range cases {
+		a, _ := Parse(c.a)
+		b, _ := Parse(c.b)
+		if got := Compare(a, b); got != c.exp {
+			t.Errorf("%s vs %s: exp is %d != %d", c.a, c.b, c.exp, got)
+		}
+	}
+
+}
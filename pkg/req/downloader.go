@@ -0,0 +1,51 @@
+package req
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DownloadRequest describes a single file to fetch.
+type DownloadRequest struct {
+	URL     string
+	Path    string
+	Header  http.Header
+	Timeout time.Duration
+}
+
+// DownloadResult reports the outcome of a successful fetch.
+type DownloadResult struct {
+	Bytes int64
+}
+
+// Downloader fetches a URL into a local file.
+type Downloader interface {
+	Fetch(ctx context.Context, r DownloadRequest) (DownloadResult, error)
+}
+
+// HTTPDownloader is the Downloader used by the command. It writes the body
+// straight to r.Path, honouring ctx, r.Header and r.Timeout.
+type HTTPDownloader struct{}
+
+func (HTTPDownloader) Fetch(ctx context.Context, r DownloadRequest) (DownloadResult, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	n, err := save(ctx, r.URL, r.Path, r.Header)
+	return DownloadResult{Bytes: n}, err
+}
+
+// DownloaderFunc adapts a plain download function such as Download to the
+// Downloader interface. Context, headers and timeout are not passed on.
+type DownloaderFunc func(url string, path string) (int64, error)
+
+func (f DownloaderFunc) Fetch(ctx context.Context, r DownloadRequest) (DownloadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return DownloadResult{}, err
+	}
+	n, err := f(r.URL, r.Path)
+	return DownloadResult{Bytes: n}, err
+}
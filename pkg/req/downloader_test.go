@@ -0,0 +1,94 @@
+package req
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHTTPDownloader_Header(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "out")
+	orgStdout := os.Stdout
+
+	defer func() {
+		os.Stdout = orgStdout
+	}()
+	os.Stdout = nil
+
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Token")))
+	}))
+	defer tsrv.Close()
+
+	var d Downloader = HTTPDownloader{}
+	res, err := d.Fetch(context.Background(), DownloadRequest{
+		URL:    tsrv.URL,
+		Path:   path,
+		Header: http.Header{"X-Token": []string{"secret"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "secret" || res.Bytes != 6 {
+		t.Errorf("exp is secret != %s (%d)", data, res.Bytes)
+	}
+
+}
+
+func TestHTTPDownloader_Timeout(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "out")
+	orgStdout := os.Stdout
+
+	defer func() {
+		os.Stdout = orgStdout
+	}()
+	os.Stdout = nil
+
+	done := make(chan struct{})
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer tsrv.Close()
+	defer close(done)
+
+	_, err := HTTPDownloader{}.Fetch(context.Background(), DownloadRequest{URL: tsrv.URL, Path: path, Timeout: 50 * time.Millisecond})
+	if err == nil {
+		t.Error("exp is timeout err")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("exp is removed")
+	}
+
+}
+
+func TestDownloaderFunc(t *testing.T) {
+
+	var got string
+	var d Downloader = DownloaderFunc(func(url string, path string) (int64, error) {
+		got = url + " " + path
+		return 3, nil
+	})
+
+	res, err := d.Fetch(context.Background(), DownloadRequest{URL: "http://x/a", Path: "./a"})
+	if err != nil || res.Bytes != 3 || got != "http://x/a ./a" {
+		t.Errorf("unexpected %v %d %s", err, res.Bytes, got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := d.Fetch(ctx, DownloadRequest{}); err == nil {
+		t.Error("exp is err")
+	}
+
+}
@@ -0,0 +1,69 @@
+package req
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter, when set, spaces out requests made to the same host.
+var Limiter *HostLimiter
+
+// HostLimiter lets at most one request per Interval through for each host.
+type HostLimiter struct {
+	Interval time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+// NewHostLimiter allows perSecond requests per second to each host.
+// perSecond should come from ParseRate, which rejects rates whose interval
+// does not fit a time.Duration.
+func NewHostLimiter(perSecond float64) *HostLimiter {
+	return &HostLimiter{Interval: rateInterval(perSecond)}
+}
+
+func rateInterval(perSecond float64) time.Duration {
+	return time.Duration(float64(time.Second) / perSecond)
+}
+
+// ParseRate reads a rate such as "5" or "5/s".
+func ParseRate(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "/s"), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || v <= 0 {
+		return 0, fmt.Errorf("invalid rate %q: expected a positive number of requests per second like \"5/s\"", s)
+	}
+	if interval := float64(time.Second) / v; interval >= math.MaxInt64 || rateInterval(v) <= 0 {
+		return 0, fmt.Errorf("invalid rate %q: out of range", s)
+	}
+	return v, nil
+}
+
+// Wait blocks until a request to host may proceed or ctx is done.
+func (l *HostLimiter) Wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	if l.next == nil {
+		l.next = map[string]time.Time{}
+	}
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.Interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,54 @@
+package req
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHostLimiter_Wait(t *testing.T) {
+
+	l := &HostLimiter{Interval: 50 * time.Millisecond}
+	ctx := context.Background()
+
+	start := time.Now()
+	l.Wait(ctx, "a.example.com")
+	l.Wait(ctx, "b.example.com")
+	if time.Since(start) > 40*time.Millisecond {
+		t.Error("exp is no wait across hosts")
+	}
+
+	l.Wait(ctx, "a.example.com")
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("exp is wait for same host")
+	}
+
+}
+
+func TestHostLimiter_Cancel(t *testing.T) {
+
+	l := &HostLimiter{Interval: time.Hour}
+	l.Wait(context.Background(), "a.example.com")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx, "a.example.com"); err == nil {
+		t.Error("exp is err")
+	}
+
+}
+
+func TestParseRate(t *testing.T) {
+
+	for in, exp := range map[string]float64{"5": 5, "2/s": 2, "0.5/s": 0.5} {
+		if got, err := ParseRate(in); err != nil || got != exp {
+			t.Errorf("%s: exp is %v != %v", in, exp, got)
+		}
+	}
+	for _, in := range []string{"", "0", "-1/s", "fast", "NaN", "Inf/s", "-Inf", "1e-300/s", "1e10/s"} {
+		if _, err := ParseRate(in); err == nil {
+			t.Errorf("%s: exp is err", in)
+		}
+	}
+
+}
@@ -0,0 +1,137 @@
+package req
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Netrc, when set, supplies basic auth for requests without an
+// Authorization header.
+var Netrc *NetrcFile
+
+// NetrcFile holds the credentials of a .netrc file.
+type NetrcFile struct {
+	machines map[string]netrcLogin
+	fallback *netrcLogin
+}
+
+type netrcLogin struct {
+	login    string
+	password string
+}
+
+// DefaultNetrcPath returns $NETRC, or .netrc in the home directory.
+func DefaultNetrcPath() string {
+	if p := os.Getenv("NETRC"); "" != p {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// LoadNetrc reads the .netrc file at path.
+func LoadNetrc(path string) (*NetrcFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseNetrc(f)
+}
+
+// ParseNetrc reads machine, default, login and password entries from r.
+// Macro definitions are skipped.
+func ParseNetrc(r io.Reader) (*NetrcFile, error) {
+	n := &NetrcFile{machines: map[string]netrcLogin{}}
+
+	var tokens []string
+	scanner := bufio.NewScanner(r)
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			inMacro = "" != strings.TrimSpace(line)
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for i, f := range fields {
+			if "macdef" == f {
+				inMacro = true
+				fields = fields[:i]
+				break
+			}
+		}
+		tokens = append(tokens, fields...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var host string
+	var cur *netrcLogin
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		if "" == host {
+			n.fallback = cur
+		} else if _, ok := n.machines[host]; !ok {
+			n.machines[host] = *cur
+		}
+	}
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "default":
+			flush()
+			host, cur = "", &netrcLogin{}
+			continue
+		case "machine", "login", "password", "account":
+		default:
+			return nil, fmt.Errorf("netrc: unexpected token %q", tokens[i])
+		}
+		if i+1 >= len(tokens) {
+			return nil, fmt.Errorf("netrc: missing value for %q", tokens[i])
+		}
+		key, val := tokens[i], tokens[i+1]
+		i++
+		switch key {
+		case "machine":
+			flush()
+			host, cur = val, &netrcLogin{}
+		case "login", "password":
+			if cur == nil {
+				return nil, fmt.Errorf("netrc: %q before machine", key)
+			}
+			if "login" == key {
+				cur.login = val
+			} else {
+				cur.password = val
+			}
+		}
+	}
+	flush()
+
+	return n, nil
+}
+
+// Credentials returns the login and password for host, falling back to the
+// default entry.
+func (n *NetrcFile) Credentials(host string) (string, string, bool) {
+	if l, ok := n.machines[host]; ok {
+		return l.login, l.password, true
+	}
+	if n.fallback != nil {
+		return n.fallback.login, n.fallback.password, true
+	}
+	return "", "", false
+}
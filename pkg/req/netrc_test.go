@@ -0,0 +1,88 @@
+package req
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+
+	n, err := ParseNetrc(strings.NewReader(`
+# comment
+machine example.com login alice password s3cret
+machine other.test
+  login bob
+  password hunter2
+macdef init
+  cd /pub
+
+default login anon password guest
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l, p, ok := n.Credentials("example.com"); !ok || l != "alice" || p != "s3cret" {
+		t.Errorf("unexpected %s %s %v", l, p, ok)
+	}
+	if l, p, ok := n.Credentials("other.test"); !ok || l != "bob" || p != "hunter2" {
+		t.Errorf("unexpected %s %s %v", l, p, ok)
+	}
+	if l, _, ok := n.Credentials("unknown.test"); !ok || l != "anon" {
+		t.Errorf("exp is default entry: %s %v", l, ok)
+	}
+
+}
+
+func TestParseNetrc_Invalid(t *testing.T) {
+
+	for _, in := range []string{"machine", "login alice", "machine a bogus x"} {
+		if _, err := ParseNetrc(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: exp is err", in)
+		}
+	}
+
+}
+
+func TestDownload_Netrc(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "out")
+	orgStdout := os.Stdout
+
+	defer func() {
+		os.Stdout = orgStdout
+		Netrc = nil
+	}()
+	os.Stdout = nil
+
+	var auth []string
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		auth = append(auth, user+":"+pass+":"+r.Header.Get("Authorization"))
+	}))
+	defer tsrv.Close()
+
+	Netrc, _ = ParseNetrc(strings.NewReader("machine 127.0.0.1 login alice password s3cret"))
+
+	if _, err := Download(tsrv.URL+"/file", path); err != nil {
+		t.Fatal(err)
+	}
+	_, err := HTTPDownloader{}.Fetch(context.Background(), DownloadRequest{
+		URL:    tsrv.URL + "/file",
+		Path:   path,
+		Header: http.Header{"Authorization": []string{"Bearer token"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(auth) != 2 || !strings.HasPrefix(auth[0], "alice:s3cret:") || auth[1] != "::Bearer token" {
+		t.Errorf("unexpected auth %v", auth)
+	}
+
+}
@@ -1,24 +1,104 @@
 package req
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"ppkgmgr/internal/logger"
+	"sync"
+	"time"
 )
 
-func Download(url string, path string) int64 {
+// UserAgent is sent with every download request when not empty.
+var UserAgent = ""
 
-	file, err := os.Create(path)
+// Insecure disables TLS certificate verification for downloads.
+var Insecure = false
 
+// MaxSize rejects downloads larger than this many bytes when positive.
+var MaxSize int64 = 0
+
+// MaxRedirects caps the redirects followed per request; 0 refuses any.
+var MaxRedirects = 10
+
+// Offline refuses every request instead of touching the network.
+var Offline = false
+
+// StrictSize fails a download whose length differs from Content-Length
+// instead of only warning about it.
+var StrictSize = false
+
+// RootCAs replaces the system certificate pool when not nil.
+var RootCAs *x509.CertPool
+
+// LoadCACert reads a PEM bundle into a certificate pool.
+func LoadCACert(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
 	if err != nil {
-		fmt.Printf("Err: %s\n", err.Error())
-		return 0
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
 	}
+	return pool, nil
+}
 
-	defer file.Close()
+func newTLSConfig() *tls.Config {
+	if !Insecure && RootCAs == nil {
+		return nil
+	}
+	return &tls.Config{InsecureSkipVerify: Insecure, RootCAs: RootCAs}
+}
+
+// tlsSettings identifies the TLS options a cached transport was built with.
+type tlsSettings struct {
+	insecure bool
+	roots    *x509.CertPool
+}
+
+var (
+	tlsMu        sync.Mutex
+	tlsKey       tlsSettings
+	tlsTransport *http.Transport
+)
+
+// newTransport returns the default transport, or a transport carrying the
+// TLS options when Insecure or RootCAs is set. That transport is reused
+// until the options change so connections stay pooled across downloads.
+func newTransport() http.RoundTripper {
+	config := newTLSConfig()
+	if config == nil {
+		return http.DefaultTransport
+	}
+
+	tlsMu.Lock()
+	defer tlsMu.Unlock()
+
+	key := tlsSettings{insecure: Insecure, roots: RootCAs}
+	if tlsTransport == nil || tlsKey != key {
+		if tlsTransport != nil {
+			tlsTransport.CloseIdleConnections()
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = config
+		tlsTransport, tlsKey = t, key
+	}
+	return tlsTransport
+}
+
+func newClient() http.Client {
+	transport := newTransport()
+	if Trace != nil {
+		transport = traceTransport{next: transport, out: Trace}
+	}
 
-	checkStatus := http.Client{
+	return http.Client{
+		Transport: transport,
 		// // proxy is os environment
 		// Transport: &http.Transport{
 		// 	Proxy:                 http.ProxyURL(proxyUrl),
@@ -28,37 +108,181 @@ func Download(url string, path string) int64 {
 		// },
 		// Timeout: time.Duration(5) * time.Second,
 		CheckRedirect: func(r *http.Request, via []*http.Request) error {
-			r.URL.Opaque = r.URL.Path
+			if len(via) > MaxRedirects {
+				if MaxRedirects == 0 {
+					return fmt.Errorf("redirect to %s refused", r.URL.Redacted())
+				}
+				return fmt.Errorf("stopped after %d redirects", MaxRedirects)
+			}
 			return nil
 		},
 	}
+}
+
+func get(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	if Offline {
+		return nil, fmt.Errorf("%s: offline, not fetching", url)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		request.Header[k] = v
+	}
+	if Netrc != nil && "" == request.Header.Get("Authorization") {
+		if login, password, ok := Netrc.Credentials(request.URL.Hostname()); ok {
+			request.SetBasicAuth(login, password)
+		}
+	}
+	if "" != UserAgent && "" == request.Header.Get("User-Agent") {
+		request.Header.Set("User-Agent", UserAgent)
+	}
+
+	if Limiter != nil {
+		if err := Limiter.Wait(ctx, request.URL.Host); err != nil {
+			return nil, err
+		}
+	}
+
+	client := newClient()
+	return client.Do(request)
+}
+
+// FetchTimeout bounds a whole Fetch when positive.
+var FetchTimeout = 30 * time.Second
+
+// FetchMaxSize rejects Fetch bodies larger than this many bytes when
+// positive. A smaller MaxSize takes precedence.
+var FetchMaxSize int64 = 8 << 20
+
+// Fetch returns the body of url, applying FetchTimeout and the smaller of
+// FetchMaxSize and MaxSize.
+func Fetch(url string) ([]byte, error) {
+	ctx := context.Background()
+	if FetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, FetchTimeout)
+		defer cancel()
+	}
+
+	response, err := get(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, response.Status)
+	}
+
+	limit := FetchMaxSize
+	if MaxSize > 0 && (limit <= 0 || MaxSize < limit) {
+		limit = MaxSize
+	}
+	if limit <= 0 {
+		return io.ReadAll(response.Body)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, limit+1))
+	if err == nil && int64(len(body)) > limit {
+		return nil, fmt.Errorf("%s: exceeds max size (%d bytes)", url, limit)
+	}
+	return body, err
+}
+
+// PartSuffix is appended to path while a download is in progress.
+const PartSuffix = ".part"
+
+// Download saves url to path and returns the number of bytes written.
+// The body is staged in a sibling ".part" file and renamed into place once
+// complete; on failure the partial file is removed.
+func Download(url string, path string) (int64, error) {
+
+	part := path + PartSuffix
+	dlsize, err := Save(url, part)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(part, path); err != nil {
+		os.Remove(part)
+		return 0, err
+	}
+
+	logger.Std.Info(logger.Event{Event: "download_finished", URL: url, Path: path, Bytes: dlsize}, "downloaded: %s => %s\n", url, path)
+
+	return dlsize, nil
+
+}
+
+// Save writes the body of url directly to path, removing it on failure.
+func Save(url string, path string) (int64, error) {
+	return save(context.Background(), url, path, nil)
+}
+
+func save(ctx context.Context, url string, path string, header http.Header) (int64, error) {
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+
+	if err != nil {
+		return 0, err
+	}
 
-	response, err := checkStatus.Get(url)
+	defer file.Close()
 
+	logger.Std.Info(logger.Event{Event: "download_started", URL: url, Path: path}, "")
+
+	dlsize, err := copyResponse(ctx, url, path, header, file)
+	if err == nil {
+		err = file.Close()
+	}
 	if err != nil {
-		fmt.Printf("Err: %s\n", err.Error())
-		return 0
+		file.Close()
+		os.Remove(path)
+		return 0, err
+	}
+
+	return dlsize, nil
+
+}
+
+func copyResponse(ctx context.Context, url string, path string, header http.Header, w io.Writer) (int64, error) {
+
+	response, err := get(ctx, url, header)
+
+	if err != nil {
+		return 0, err
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
-		fmt.Printf("Err: %s\n", url)
-		return 0
+		return 0, fmt.Errorf("%s: %s", url, response.Status)
 	}
 
 	filesize := response.ContentLength
-	dlsize, err := io.Copy(file, response.Body)
+	if MaxSize > 0 && filesize > MaxSize {
+		return 0, fmt.Errorf("%s: exceeds max size (%d > %d bytes)", url, filesize, MaxSize)
+	}
+
+	var body io.Reader = response.Body
+	if MaxSize > 0 {
+		body = io.LimitReader(response.Body, MaxSize+1)
+	}
+	dlsize, err := io.Copy(w, body)
+	if err == nil && MaxSize > 0 && dlsize > MaxSize {
+		return 0, fmt.Errorf("%s: exceeds max size (%d bytes)", url, MaxSize)
+	}
 	if (filesize != -1) && (dlsize != filesize) {
-		fmt.Printf("Truncated: %s\n", url)
+		if StrictSize && err == nil {
+			return 0, fmt.Errorf("%s: truncated (%d of %d bytes)", url, dlsize, filesize)
+		}
+		logger.Std.Error(logger.Event{Event: "truncated", URL: url, Path: path, Bytes: dlsize}, "Truncated: %s\n", url)
 	}
 
 	if err != nil {
-		fmt.Printf("Err: %s\n", err.Error())
-		return 0
+		return 0, err
 	}
 
-	fmt.Printf("downloaded: %s => %s\n", url, path)
-
-	return dlsize
+	return dlsize, nil
 
 }
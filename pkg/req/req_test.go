@@ -1,11 +1,18 @@
 package req
 
 import (
+	"compress/gzip"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDownload_FileSize(t *testing.T) {
@@ -35,3 +42,385 @@ func TestDownload_FileSize(t *testing.T) {
 	}
 
 }
+
+func TestDownload_UserAgent(t *testing.T) {
+
+	tmpFile, _ := ioutil.TempFile("", "tmpfile")
+	defer os.Remove(tmpFile.Name())
+	orgStdout := os.Stdout
+
+	orgUserAgent := UserAgent
+	defer func() {
+		os.Stdout = orgStdout
+		UserAgent = orgUserAgent
+	}()
+	os.Stdout = nil
+	UserAgent = "ppkgmgr/1.2.3"
+
+	var got string
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.UserAgent()
+	}))
+	defer tsrv.Close()
+
+	Download(tsrv.URL, tmpFile.Name())
+
+	if got != "ppkgmgr/1.2.3" {
+		t.Errorf("exp is ppkgmgr/1.2.3 != %s", got)
+	}
+
+}
+
+func TestDownload_NotFound(t *testing.T) {
+
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "out")
+
+	tsrv := httptest.NewServer(http.NotFoundHandler())
+	defer tsrv.Close()
+
+	if _, err := Download(tsrv.URL, path); err == nil {
+		t.Error("exp is err")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("exp is removed")
+	}
+	if _, err := os.Stat(path + PartSuffix); !os.IsNotExist(err) {
+		t.Error("exp is part removed")
+	}
+
+}
+
+func TestDownload_NoPartLeft(t *testing.T) {
+
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "out")
+	orgStdout := os.Stdout
+
+	defer func() {
+		os.Stdout = orgStdout
+	}()
+	os.Stdout = nil
+
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer tsrv.Close()
+
+	if _, err := Download(tsrv.URL, path); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 || entries[0].Name() != "out" {
+		t.Errorf("exp is only out: %v", entries)
+	}
+
+}
+
+func TestDownload_Insecure(t *testing.T) {
+
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "out")
+	orgStdout := os.Stdout
+
+	defer func() {
+		os.Stdout = orgStdout
+		Insecure = false
+	}()
+	os.Stdout = nil
+
+	tsrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer tsrv.Close()
+
+	if _, err := Download(tsrv.URL, path); err == nil {
+		t.Error("exp is certificate err")
+	}
+
+	Insecure = true
+	if _, err := Download(tsrv.URL, path); err != nil {
+		t.Errorf("exp is nil: %v", err)
+	}
+
+}
+
+func TestDownload_CACert(t *testing.T) {
+
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "out")
+	orgStdout := os.Stdout
+
+	defer func() {
+		os.Stdout = orgStdout
+		RootCAs = nil
+	}()
+	os.Stdout = nil
+
+	tsrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer tsrv.Close()
+
+	caPath := filepath.Join(tmpDir, "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: tsrv.Certificate().Raw}
+	ioutil.WriteFile(caPath, pem.EncodeToMemory(block), 0644)
+
+	pool, err := LoadCACert(caPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RootCAs = pool
+
+	if _, err := Download(tsrv.URL, path); err != nil {
+		t.Errorf("exp is nil: %v", err)
+	}
+
+}
+
+func TestNewTransport_Reused(t *testing.T) {
+
+	defer func() {
+		Insecure = false
+		RootCAs = nil
+	}()
+
+	if newTransport() != http.DefaultTransport {
+		t.Error("exp is default transport")
+	}
+
+	Insecure = true
+	first := newTransport()
+	if first == http.DefaultTransport || newTransport() != first {
+		t.Error("exp is one shared insecure transport")
+	}
+
+	RootCAs = x509.NewCertPool()
+	if newTransport() == first {
+		t.Error("exp is new transport after options change")
+	}
+
+}
+
+func TestLoadCACert_Invalid(t *testing.T) {
+
+	tmpDir := t.TempDir()
+	caPath := filepath.Join(tmpDir, "ca.pem")
+	ioutil.WriteFile(caPath, []byte("not a certificate"), 0644)
+
+	if _, err := LoadCACert(caPath); err == nil {
+		t.Error("exp is err")
+	}
+	if _, err := LoadCACert(filepath.Join(tmpDir, "missing.pem")); err == nil {
+		t.Error("exp is err")
+	}
+
+}
+
+func TestDownload_MaxSize(t *testing.T) {
+
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "out")
+
+	defer func() {
+		MaxSize = 0
+	}()
+	MaxSize = 4
+
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer tsrv.Close()
+
+	for _, u := range []string{tsrv.URL + "/sized", tsrv.URL + "/chunked"} {
+		if _, err := Download(u, path); err == nil || !strings.Contains(err.Error(), "exceeds max size") {
+			t.Errorf("%s: exp is max size err: %v", u, err)
+		}
+		if _, err := os.Stat(path + PartSuffix); !os.IsNotExist(err) {
+			t.Errorf("%s: exp is part removed", u)
+		}
+	}
+
+}
+
+func TestDownload_Redirect(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "out")
+	orgStdout := os.Stdout
+
+	defer func() {
+		os.Stdout = orgStdout
+		MaxRedirects = 10
+	}()
+	os.Stdout = nil
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/assets/tool" || r.URL.Query().Get("sig") != "a/b+c" {
+			http.Error(w, r.URL.String(), http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("signed"))
+	}))
+	defer target.Close()
+
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/assets/tool?sig=a%2Fb%2Bc", http.StatusFound)
+	}))
+	defer tsrv.Close()
+
+	if _, err := Download(tsrv.URL+"/releases/tool", path); err != nil {
+		t.Fatalf("exp is nil: %v", err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	if string(data) != "signed" {
+		t.Errorf("exp is signed != %s", data)
+	}
+
+	MaxRedirects = 0
+	if _, err := Download(tsrv.URL+"/releases/tool", path); err == nil {
+		t.Error("exp is redirect refused")
+	}
+
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestDownload_StrictSize(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "out")
+	orgStdout := os.Stdout
+	orgTransport := http.DefaultTransport
+
+	defer func() {
+		os.Stdout = orgStdout
+		http.DefaultTransport = orgTransport
+		StrictSize = false
+	}()
+	os.Stdout = nil
+
+	// a server that declares more bytes than it sends without the body
+	// reporting an error
+	http.DefaultTransport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Status:        "200 OK",
+			ContentLength: 10,
+			Body:          io.NopCloser(strings.NewReader("hello")),
+			Request:       r,
+		}, nil
+	})
+
+	if _, err := Download("http://example.test/file", path); err != nil {
+		t.Errorf("exp is warning only: %v", err)
+	}
+
+	StrictSize = true
+	os.Remove(path)
+	_, err := Download("http://example.test/file", path)
+	if err == nil || !strings.Contains(err.Error(), "truncated (5 of 10 bytes)") {
+		t.Errorf("exp is truncated err: %v", err)
+	}
+	if _, err := os.Stat(path + PartSuffix); !os.IsNotExist(err) {
+		t.Error("exp is part removed")
+	}
+
+}
+
+func TestDownload_Offline(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "out")
+
+	hits := 0
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer tsrv.Close()
+
+	defer func() {
+		Offline = false
+	}()
+	Offline = true
+
+	if _, err := Download(tsrv.URL+"/file", path); err == nil || !strings.Contains(err.Error(), "offline") {
+		t.Errorf("exp is offline err: %v", err)
+	}
+	if _, err := Fetch(tsrv.URL + "/file"); err == nil {
+		t.Error("exp is offline err")
+	}
+	if hits != 0 {
+		t.Errorf("exp is no request != %d", hits)
+	}
+
+}
+
+func TestFetch_Gzip(t *testing.T) {
+
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Write([]byte("plain"))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte("repositories: []\n"))
+		gz.Close()
+	}))
+	defer tsrv.Close()
+
+	body, err := Fetch(tsrv.URL + "/manifest.yml")
+	if err != nil || string(body) != "repositories: []\n" {
+		t.Errorf("exp is decoded body != %q (%v)", body, err)
+	}
+
+}
+
+func TestFetch_Limits(t *testing.T) {
+
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.Write([]byte(strings.Repeat("x", 32)))
+	}))
+	defer tsrv.Close()
+
+	defer func() {
+		FetchTimeout = 30 * time.Second
+		FetchMaxSize = 8 << 20
+	}()
+	FetchTimeout = 50 * time.Millisecond
+	FetchMaxSize = 16
+
+	if _, err := Fetch(tsrv.URL + "/slow"); err == nil {
+		t.Error("exp is timeout err")
+	}
+	if _, err := Fetch(tsrv.URL + "/big"); err == nil || !strings.Contains(err.Error(), "exceeds max size") {
+		t.Errorf("exp is max size err: %v", err)
+	}
+
+	FetchMaxSize = 32
+	if body, err := Fetch(tsrv.URL + "/big"); err != nil || len(body) != 32 {
+		t.Errorf("exp is 32 bytes != %d (%v)", len(body), err)
+	}
+
+	defer func() {
+		MaxSize = 0
+	}()
+	MaxSize = 8
+	if _, err := Fetch(tsrv.URL + "/big"); err == nil || !strings.Contains(err.Error(), "exceeds max size (8 bytes)") {
+		t.Errorf("exp is -max-size applied: %v", err)
+	}
+
+}
@@ -0,0 +1,46 @@
+package req
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = []struct {
+	suffix string
+	scale  int64
+}{
+	{"KIB", 1 << 10},
+	{"MIB", 1 << 20},
+	{"GIB", 1 << 30},
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"B", 1},
+}
+
+// ParseSize reads a byte count such as "1048576", "100MB" or "1GiB".
+func ParseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	scale := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(v, u.suffix) {
+			v = strings.TrimSpace(strings.TrimSuffix(v, u.suffix))
+			scale = u.scale
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q: expected bytes or a value like \"100MB\"", s)
+	}
+	if n > math.MaxInt64/scale {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return n * scale, nil
+}
@@ -0,0 +1,29 @@
+package req
+
+import (
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+
+	cases := map[string]int64{
+		"1024":  1024,
+		"100MB": 100 * 1000 * 1000,
+		"1GiB":  1 << 30,
+		"512k":  512 << 10,
+		"2 MiB": 2 << 20,
+		"10B":   10,
+	}
+	for in, exp := range cases {
+		if got, err := ParseSize(in); err != nil || got != exp {
+			t.Errorf("%s: exp is %d != %d (%v)", in, exp, got, err)
+		}
+	}
+
+	for _, in := range []string{"", "MB", "-1", "1.5GB", "lots", "9999999999GB", "9223372036854775807K"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("%s: exp is err", in)
+		}
+	}
+
+}
@@ -0,0 +1,57 @@
+package req
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Trace, when set, receives the method, URL and headers of every request
+// and the status and headers of every response. Bodies are not logged.
+var Trace io.Writer
+
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+type traceTransport struct {
+	next http.RoundTripper
+	out  io.Writer
+}
+
+func (t traceTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	fmt.Fprintf(t.out, "> %s %s\n", r.Method, r.URL.Redacted())
+	writeHeaders(t.out, ">", r.Header)
+
+	response, err := t.next.RoundTrip(r)
+	if err != nil {
+		fmt.Fprintf(t.out, "< error: %s\n", err.Error())
+		return response, err
+	}
+
+	fmt.Fprintf(t.out, "< %s %s\n", response.Proto, response.Status)
+	writeHeaders(t.out, "<", response.Header)
+
+	return response, nil
+}
+
+func writeHeaders(w io.Writer, prefix string, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := strings.Join(h[k], ", ")
+		if redactedHeaders[http.CanonicalHeaderKey(k)] {
+			v = "[REDACTED]"
+		}
+		fmt.Fprintf(w, "%s %s: %s\n", prefix, k, v)
+	}
+}
@@ -0,0 +1,52 @@
+package req
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownload_Trace(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "out")
+	orgStdout := os.Stdout
+
+	var buf bytes.Buffer
+	defer func() {
+		os.Stdout = orgStdout
+		Trace = nil
+	}()
+	os.Stdout = nil
+	Trace = &buf
+
+	tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", "test")
+		w.Write([]byte("hello"))
+	}))
+	defer tsrv.Close()
+
+	_, err := HTTPDownloader{}.Fetch(context.Background(), DownloadRequest{
+		URL:    strings.Replace(tsrv.URL, "http://", "http://user:secret@", 1) + "/file",
+		Path:   path,
+		Header: http.Header{"Authorization": []string{"Bearer secret"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, exp := range []string{"> GET " + strings.Replace(tsrv.URL, "http://", "http://user:xxxxx@", 1) + "/file", "> Authorization: [REDACTED]", "< HTTP/1.1 200 OK", "< X-Served-By: test"} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("exp is %q in %q", exp, out)
+		}
+	}
+	if strings.Contains(out, "secret") || strings.Contains(out, "hello") {
+		t.Errorf("exp is no secret or body in %q", out)
+	}
+
+}